@@ -2,55 +2,89 @@ package cli
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"log/slog"
+	"os"
 
 	"github.com/cortea-ai/pg-migrant/internal/config"
-	"github.com/cortea-ai/pg-migrant/internal/db"
+	"github.com/cortea-ai/pg-migrant/internal/engine"
+	"github.com/cortea-ai/pg-migrant/internal/metrics"
 )
 
-func Apply(ctx context.Context, conf *config.Config, autoApprove, dryRun bool) error {
-	conn, currentVersion, err := db.NewConnEnsureVersionTable(ctx, conf.GetDBUrl())
-	if err != nil {
-		return err
-	}
-	defer conn.Close(ctx)
-	versions, migrations, err := findPendingMigrations(currentVersion, conf.GetMigrationDir())
-	if err != nil {
-		return err
+// Apply applies the pending migrations. dryRun is empty to apply them, or one of the DryRun* modes.
+func Apply(ctx context.Context, conf *config.Config, autoApprove bool, dryRun string) error {
+	if dryRun != "" && dryRun != DryRunPrint && dryRun != DryRunExecute {
+		return fmt.Errorf("invalid dry run mode %q, expected %q or %q", dryRun, DryRunPrint, DryRunExecute)
 	}
-	if len(migrations) == 0 {
-		println("No pending migrations")
+	switch dryRun {
+	case DryRunPrint:
+		pending, err := engine.FindPending(ctx, conf, slog.Default())
+		if err != nil {
+			return err
+		}
+		if pending.Empty() {
+			slog.Info("no pending migrations", "version", pending.CurrentVersion)
+			return nil
+		}
+		for i, m := range pending.Migrations {
+			printMigration(fmt.Sprintf("Migration %s as %d of %d migrations:", m.Version, i+1, len(pending.Migrations)), m)
+		}
+		for i, r := range pending.Repeatables {
+			printMigration(fmt.Sprintf("Repeatable migration %s as %d of %d repeatable migrations:", r.Filename, i+1, len(pending.Repeatables)), r)
+		}
 		return nil
+	case DryRunExecute:
+		return dryRunExecute(ctx, conf)
 	}
-	for i, migration := range migrations {
-		version := versions[i]
-		println("Migration", version, "as", i+1, "of", len(migrations), "migrations:")
-		println("\n---\n")
-		println(migration)
-		println("---\n")
-		if !dryRun {
-			if !autoApprove {
-				if err := promptForApproval("Apply this migration?"); err != nil {
-					return err
-				}
+
+	var reg *metrics.Registry
+	if conf.GetMetricsConfig().Enabled() {
+		reg = metrics.New("env", conf.SelectedEnv.Name)
+	}
+	applied, err := engine.ApplyMigrations(ctx, conf, engine.ApplyOptions{
+		Approve: func(ctx context.Context, m engine.Migration) (bool, error) {
+			msg := "Apply this migration?"
+			if m.Repeatable {
+				printMigration(fmt.Sprintf("Repeatable migration %s:", m.Filename), m)
+				msg = "Apply this repeatable migration?"
+			} else {
+				printMigration(fmt.Sprintf("Migration %s:", m.Version), m)
 			}
-			if err := conn.ApplyMigration(ctx, version, migration); err != nil {
-				return err
+			if autoApprove {
+				return true, nil
 			}
+			return true, promptForApproval(msg)
+		},
+		Logger:  slog.Default(),
+		Metrics: reg,
+	})
+	if reg != nil {
+		// Failed runs are exported too, and a failed export does not fail the run.
+		if exportErr := exportMetrics(ctx, conf, reg); exportErr != nil {
+			slog.Warn("exporting metrics failed", "error", exportErr)
 		}
 	}
+	if err != nil {
+		return err
+	}
+	if len(applied) > 0 {
+		slog.Info("applied migrations", "count", len(applied))
+	}
 	return nil
 }
 
+func printMigration(header string, m engine.Migration) {
+	fmt.Printf("%s\n\n---\n\n%s\n---\n\n", header, m.Content)
+}
+
 func promptForApproval(msg string) error {
-	print(msg + " [y/N]: ")
+	fmt.Fprint(os.Stderr, msg+" [y/N]: ")
 	var response string
 	if _, err := fmt.Scanln(&response); err != nil {
 		return err
 	}
 	if response != "y" && response != "Y" {
-		return errors.New("migration aborted")
+		return engine.ErrAborted
 	}
 	return nil
 }
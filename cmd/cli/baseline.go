@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/cortea-ai/pg-migrant/internal/config"
+	"github.com/cortea-ai/pg-migrant/internal/diffutils"
+	"github.com/cortea-ai/pg-migrant/internal/engine"
+	"github.com/stripe/pg-schema-diff/pkg/diff"
+)
+
+// Baseline collapses every migration up to and including version, registered Go migrations included,
+// into a single baseline migration, generated from the schema of a temp database the migrations were
+// replayed into. Go migrations cannot be removed like files: they must be unregistered.
+func Baseline(ctx context.Context, conf *config.Config, version string) error {
+	if err := engine.ValidateVersion(version); err != nil {
+		return err
+	}
+	migrations, err := engine.LoadMigrations(conf.GetFS(), conf.GetMigrationDir())
+	if err != nil {
+		return err
+	}
+	var collapsed []engine.Migration
+	for _, m := range migrations {
+		if m.Version <= version {
+			collapsed = append(collapsed, m)
+		}
+	}
+	if len(collapsed) == 0 || collapsed[len(collapsed)-1].Version != version {
+		return fmt.Errorf("migration %s not found in %s", version, conf.GetMigrationDir())
+	}
+	if len(collapsed) == 1 && engine.IsBaseline(collapsed[0].Filename) {
+		slog.Info("migration is already a baseline", "version", version)
+		return nil
+	}
+
+	tempDbFactory, err := engine.NewTempDbFactory(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer engine.CloseTempDbFactory(slog.Default(), tempDbFactory)
+
+	emptyDb, err := tempDbFactory.Create(ctx)
+	if err != nil {
+		return fmt.Errorf("creating temp database: %w", err)
+	}
+	defer engine.CloseTempDb(ctx, slog.Default(), emptyDb)
+
+	replayedDb, err := tempDbFactory.Create(ctx)
+	if err != nil {
+		return fmt.Errorf("creating temp database: %w", err)
+	}
+	defer engine.CloseTempDb(ctx, slog.Default(), replayedDb)
+	if err := engine.ReplayMigrations(ctx, replayedDb, collapsed); err != nil {
+		return err
+	}
+
+	schemaOpts := append(engine.SchemaFilterOpts(conf), emptyDb.ExcludeMetadataOptions...)
+
+	plan, err := diff.Generate(ctx, emptyDb.ConnPool, diff.DBSchemaSource(replayedDb.ConnPool), append(engine.PlanOpts(conf.GetDiffConfig()),
+		diff.WithGetSchemaOpts(schemaOpts...),
+		diff.WithTempDbFactory(tempDbFactory),
+	)...)
+	if err != nil {
+		return err
+	}
+
+	filename := version + engine.BaselineSuffix + ".sql"
+	content := fmt.Sprintf("-- Baseline of migrations %s to %s\n\n", collapsed[0].Version, version)
+	if len(plan.Statements) > 0 {
+		content += diffutils.PlanToPrettyS(plan)
+	}
+	if err := engine.ReplayMigrations(ctx, emptyDb, []engine.Migration{{Filename: filename, Content: content}}); err != nil {
+		return fmt.Errorf("verifying baseline migration: %w", err)
+	}
+	if err := assertSameSchema(ctx, emptyDb, replayedDb, schemaOpts); err != nil {
+		return err
+	}
+
+	fmt.Println(content)
+	if err := promptForApproval(fmt.Sprintf("Replace %d migrations with %s?", len(collapsed), filename)); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(conf.GetMigrationDir(), filename), []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing baseline migration: %w", err)
+	}
+	var goMigrations []string
+	for _, m := range collapsed {
+		if m.Func != nil {
+			goMigrations = append(goMigrations, m.Filename)
+			continue
+		}
+		if m.Filename == filename {
+			continue
+		}
+		if err := os.Remove(filepath.Join(conf.GetMigrationDir(), m.Filename)); err != nil {
+			return err
+		}
+	}
+	if len(goMigrations) > 0 {
+		slog.Warn("remove the registrations of the Go migrations replaced by the baseline", "migrations", goMigrations)
+	}
+
+	slog.Info("created baseline migration", "version", version, "file", filename)
+	return nil
+}
@@ -4,21 +4,15 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
+	"log/slog"
 	"strconv"
 
 	"github.com/cortea-ai/pg-migrant/internal/config"
+	"github.com/cortea-ai/pg-migrant/internal/engine"
 	"github.com/google/go-github/github"
 	"golang.org/x/oauth2"
 )
 
-type Migration struct {
-	Filename string
-	Version  string
-	Content  string
-}
-
 func Check(ctx context.Context, conf *config.Config, token string) error {
 	tc := oauth2.NewClient(ctx, oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
@@ -39,33 +33,18 @@ func Check(ctx context.Context, conf *config.Config, token string) error {
 	}
 
 	// Get local migrations
-	localFiles, err := os.ReadDir(conf.GetMigrationDir())
+	localMigrations, err := engine.ReadMigrations(conf.GetFS(), conf.GetMigrationDir())
 	if err != nil {
-		return fmt.Errorf("failed to read local migration directory: %w", err)
-	}
-	localMigrations := make([]Migration, 0)
-	for _, file := range localFiles {
-		if file.IsDir() {
-			continue
-		}
-		content, err := os.ReadFile(filepath.Join(conf.GetMigrationDir(), file.Name()))
-		if err != nil {
-			return fmt.Errorf("failed to read migration file %s: %w", file.Name(), err)
-		}
-		version, err := VersionFromFilename(file.Name())
-		if err != nil {
-			return err
-		}
-		localMigrations = append(localMigrations, Migration{
-			Filename: file.Name(),
-			Version:  version,
-			Content:  string(content),
-		})
+		return err
 	}
 
-	// Ensure no gaps in migration versions
+	// Ensure no gaps in migration versions, which registered Go migrations may fill
+	allMigrations, err := engine.LoadMigrations(conf.GetFS(), conf.GetMigrationDir())
+	if err != nil {
+		return err
+	}
 	var prevVersion int
-	for _, m := range localMigrations {
+	for _, m := range allMigrations {
 		version, err := strconv.Atoi(m.Version)
 		if err != nil {
 			return fmt.Errorf("failed to parse version %s as number: %w", m.Version, err)
@@ -77,12 +56,12 @@ func Check(ctx context.Context, conf *config.Config, token string) error {
 	}
 
 	for i, m := range migrations {
-		println("Checking remote migration:", m.GetName())
+		slog.Info("checking remote migration", "file", m.GetName())
 		localM := localMigrations[i]
 		if m.GetName() != localM.Filename {
 			return fmt.Errorf("migration %s exists in remote but not locally", m.GetName())
 		}
-		version, err := VersionFromFilename(m.GetName())
+		version, err := engine.VersionFromFilename(m.GetName())
 		if err != nil {
 			return err
 		}
@@ -98,7 +77,7 @@ func Check(ctx context.Context, conf *config.Config, token string) error {
 		}
 	}
 
-	println("\n✅ All migrations are in sync\n")
+	slog.Info("all migrations are in sync")
 
 	return nil
 }
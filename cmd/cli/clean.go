@@ -3,9 +3,12 @@ package cli
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"strings"
 
 	"github.com/cortea-ai/pg-migrant/internal/config"
 	"github.com/cortea-ai/pg-migrant/internal/db"
+	"github.com/cortea-ai/pg-migrant/internal/engine"
 )
 
 func Clean(ctx context.Context, conf *config.Config) error {
@@ -17,12 +20,17 @@ func Clean(ctx context.Context, conf *config.Config) error {
 		return err
 	}
 	defer conn.Close(ctx)
-	if err := promptForApproval("Clean database schema?"); err != nil {
+	schemas := engine.ManagedSchemas(conf)
+	prompt := "Drop the migration history?"
+	if len(schemas) > 0 {
+		prompt = fmt.Sprintf("Drop and recreate schemas %s, and drop the migration history?", strings.Join(schemas, ", "))
+	}
+	if err := promptForApproval(prompt); err != nil {
 		return err
 	}
-	if err := conn.CleanSchema(ctx); err != nil {
+	if err := conn.CleanSchemas(ctx, schemas); err != nil {
 		return err
 	}
-	fmt.Printf("\n✅ Cleaned database schema\n")
+	slog.Info("cleaned database schema")
 	return nil
 }
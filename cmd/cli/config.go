@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cortea-ai/pg-migrant/internal/config"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+func ConfigShow(conf *config.Config) error {
+	env := conf.SelectedEnv
+	fmt.Printf("env %q\n", env.Name)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, setting := range env.Settings() {
+		origin := setting.Origin
+		if origin == "" {
+			origin = "unset"
+		}
+		value := setting.Value
+		if setting.Name == "db_url" {
+			value = redactURL(env.DBUrl)
+		}
+		fmt.Fprintf(w, "  %s\t= %s\t# %s\n", setting.Name, config.Redact(formatSetting(value)), origin)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(conf.Variables) == 0 {
+		return nil
+	}
+	fmt.Println("\nvariables")
+	for _, v := range conf.Variables {
+		value := config.Redacted
+		if !v.Sensitive {
+			value = string(hclwrite.TokensForValue(v.Value).Bytes())
+		}
+		fmt.Fprintf(w, "  %s\t= %s", v.Name, value)
+		if v.Description != "" {
+			fmt.Fprintf(w, "\t# %s", v.Description)
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
+
+func ConfigValidate(conf *config.Config) error {
+	errs := conf.Validate()
+	for _, err := range errs {
+		slog.Error("invalid config", "error", err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("found %d problems in config", len(errs))
+	}
+	slog.Info("config is valid")
+	return nil
+}
+
+func formatSetting(value any) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case []string:
+		return fmt.Sprintf("%q", v)
+	default:
+		return fmt.Sprintf("%+v", v)
+	}
+}
+
+// dsnPassword matches the password of a key=value connection string, quoted or not. Digits may precede
+// the key, so that a password separated by an escaped space such as %20 is matched too.
+var dsnPassword = regexp.MustCompile(`(?i)((?:^|[^a-z_])password\s*=\s*)(?:'(?:[^'\\]|\\.)*'|\S*)`)
+
+// redactURL masks the password of a connection URL, given in its user info or its query, or of a
+// key=value connection string. A value that is neither is masked whole.
+func redactURL(dbURL string) string {
+	if strings.HasPrefix(dbURL, "postgres://") || strings.HasPrefix(dbURL, "postgresql://") {
+		u, err := url.Parse(dbURL)
+		if err != nil {
+			return config.Redacted
+		}
+		if q := u.Query(); q.Has("password") {
+			q.Set("password", "xxxxx")
+			u.RawQuery = q.Encode()
+		}
+		return u.Redacted()
+	}
+	if !strings.Contains(dbURL, "=") {
+		return config.Redacted
+	}
+	return dsnPassword.ReplaceAllString(dbURL, "${1}"+config.Redacted)
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/cortea-ai/pg-migrant/internal/config"
 	"github.com/cortea-ai/pg-migrant/internal/db"
@@ -14,9 +15,9 @@ func DBLastMigration(ctx context.Context, conf *config.Config) error {
 	}
 	defer conn.Close(ctx)
 	if currentVersion != "" {
-		println("Current version:", currentVersion)
+		fmt.Println("Current version:", currentVersion)
 	} else {
-		println("No migrations applied yet")
+		fmt.Println("No migrations applied yet")
 	}
 	return nil
 }
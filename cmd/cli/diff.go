@@ -2,81 +2,28 @@ package cli
 
 import (
 	"context"
-	"database/sql"
-	"errors"
 	"fmt"
 	"io/fs"
-	"net/url"
+	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/cortea-ai/pg-migrant/internal/config"
 	"github.com/cortea-ai/pg-migrant/internal/db"
-	"github.com/cortea-ai/pg-migrant/internal/diffutils"
-	"github.com/stripe/pg-schema-diff/pkg/diff"
-	"github.com/stripe/pg-schema-diff/pkg/tempdb"
+	"github.com/cortea-ai/pg-migrant/internal/engine"
+	"github.com/cortea-ai/pg-migrant/internal/fsutil"
 )
 
 func Diff(ctx context.Context, conf *config.Config, migrate bool) error {
-	if len(conf.GetSchemaFiles()) == 0 {
-		return errors.New("no schema files provided")
-	}
-
-	dbConfig, err := conf.GetDBConfig()
-	if err != nil {
-		return err
-	}
-
-	tempDbFactory, err := tempdb.NewOnInstanceFactory(ctx,
-		func(ctx context.Context, dbName string) (*sql.DB, error) {
-			connUrl, err := url.Parse(conf.GetDBUrl())
-			if err != nil {
-				return nil, fmt.Errorf("invalid connection string: %w", err)
-			}
-			connUrl.Path = "/" + dbName
-			conn, err := db.NewConn(ctx, connUrl.String())
-			if err != nil {
-				return nil, err
-			}
-			return conn.DB, nil
-		},
-		tempdb.WithRootDatabase(dbConfig.Database),
-	)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		err := tempDbFactory.Close()
-		if err != nil {
-			fmt.Printf("error shutting down temp db factory: %v", err)
-		}
-	}()
-
-	conn, _, err := db.NewConnEnsureVersionTable(ctx, conf.GetDBUrl())
-	if err != nil {
-		return err
-	}
-	defer conn.Close(ctx)
-
-	ddls, err := diffutils.GetDDLsFromFiles(conf.GetSchemaFiles())
+	plan, err := engine.GeneratePlan(ctx, conf, slog.Default())
 	if err != nil {
 		return err
 	}
-	schemaSource := diff.DDLSchemaSource(ddls)
-
-	plan, err := diff.Generate(ctx, conn, schemaSource,
-		diff.WithDataPackNewTables(),
-		diff.WithExcludeSchemas(append(conf.GetExcludeSchemas(), db.PGMigrantSchema)...),
-		diff.WithTempDbFactory(tempDbFactory),
-	)
-	if err != nil {
-		return err
-	}
-
 	if len(plan.Statements) == 0 {
-		println("schema matches expected. No plan generated")
+		slog.Info("schema matches expected, no plan generated")
 		return nil
 	}
 
@@ -86,9 +33,8 @@ func Diff(ctx context.Context, conf *config.Config, migrate bool) error {
 	}
 
 	var maxVersion string
-	var lastFile fs.DirEntry
+	var sqlFiles []string
 	for _, file := range files {
-		lastFile = file
 		if file.IsDir() {
 			continue
 		}
@@ -96,60 +42,87 @@ func Diff(ctx context.Context, conf *config.Config, migrate bool) error {
 		if !strings.HasSuffix(strings.ToLower(name), ".sql") {
 			continue
 		}
-		version, err := VersionFromFilename(name)
+		version, err := engine.VersionFromFilename(name)
 		if err != nil {
 			continue
 		}
+		sqlFiles = append(sqlFiles, name)
 		if version > maxVersion {
 			maxVersion = version
 		}
 	}
 
-	if lastFile != nil {
-		lastFilePath := filepath.Join(conf.GetMigrationDir(), lastFile.Name())
-		lastContent, err := os.ReadFile(lastFilePath)
-		if err != nil {
-			return fmt.Errorf("reading last migration file: %w", err)
+	migrations := plan.Migrations
+	if len(sqlFiles) >= len(migrations) {
+		matches := true
+		for i, name := range sqlFiles[len(sqlFiles)-len(migrations):] {
+			content, err := fs.ReadFile(conf.GetFS(), path.Join(fsutil.Clean(conf.GetMigrationDir()), name))
+			if err != nil {
+				return fmt.Errorf("reading last migration file: %w", err)
+			}
+			matches = matches && string(content) == migrations[i]
 		}
-		if string(lastContent) == diffutils.PlanToPrettyS(plan) {
-			println("No changes detected - migration content matches last file")
+		if matches {
+			slog.Info("no changes detected, migration content matches last file")
 			return nil
 		}
 	}
 
-	println(diffutils.PlanToPrettyS(plan))
-
-	newVersionStr := "0000"
+	nextVersion := 0
 	if maxVersion != "" {
-		newVersion, err := strconv.Atoi(maxVersion)
+		version, err := strconv.Atoi(maxVersion)
 		if err != nil {
 			return fmt.Errorf("invalid max version: %w", err)
 		}
-		newVersionStr = fmt.Sprintf("%04d", newVersion+1)
+		nextVersion = version + 1
+	}
+	versions := make([]string, len(migrations))
+	for i, migration := range migrations {
+		versions[i] = fmt.Sprintf("%04d", nextVersion+i)
+		if len(migrations) > 1 {
+			fmt.Printf("-- %s.sql\n", versions[i])
+		}
+		fmt.Println(migration)
 	}
 
 	if migrate {
-		if err := promptForApproval("Apply this migration?"); err != nil {
+		msg := "Apply this migration?"
+		if len(migrations) > 1 {
+			msg = fmt.Sprintf("Apply these %d migrations?", len(migrations))
+		}
+		if err := promptForApproval(msg); err != nil {
 			return err
 		}
-		if err := conn.ApplyMigration(ctx, newVersionStr, diffutils.PlanToPrettyS(plan)); err != nil {
+		conn, _, err := db.NewConnEnsureVersionTable(ctx, conf.GetDBUrl())
+		if err != nil {
 			return err
 		}
+		defer conn.Close(ctx)
+		for i, migration := range migrations {
+			if err := conn.ApplyMigration(ctx, versions[i], migration); err != nil {
+				return err
+			}
+			slog.Info("applied migration", "version", versions[i])
+		}
 		if conf.GetMigrationDir() == "" {
 			return nil
 		}
 	}
 
-	newFilePath := filepath.Join(conf.GetMigrationDir(), fmt.Sprintf("%s.sql", newVersionStr))
-	if err := promptForApproval("Create new migration file?"); err != nil {
+	msg := "Create new migration file?"
+	if len(migrations) > 1 {
+		msg = fmt.Sprintf("Create %d new migration files?", len(migrations))
+	}
+	if err := promptForApproval(msg); err != nil {
 		return err
 	}
-	err = os.WriteFile(newFilePath, []byte(diffutils.PlanToPrettyS(plan)), 0644)
-	if err != nil {
-		return fmt.Errorf("writing migration file: %w", err)
+	for i, migration := range migrations {
+		newFilePath := filepath.Join(conf.GetMigrationDir(), fmt.Sprintf("%s.sql", versions[i]))
+		if err := os.WriteFile(newFilePath, []byte(migration), 0644); err != nil {
+			return fmt.Errorf("writing migration file: %w", err)
+		}
+		slog.Info("created migration file", "version", versions[i], "path", newFilePath)
 	}
 
-	fmt.Printf("\n✅ Created new migration file: %s\n", newFilePath)
-
 	return nil
 }
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/cortea-ai/pg-migrant/internal/config"
+	"github.com/cortea-ai/pg-migrant/internal/engine"
+)
+
+// Dry run modes of apply.
+const (
+	// DryRunPrint prints the pending migrations.
+	DryRunPrint = "print"
+	// DryRunExecute executes the pending migrations in a transaction that is rolled back.
+	DryRunExecute = "execute"
+)
+
+// dryRunExecute executes the pending migrations in a transaction that is rolled back, and prints the
+// outcome of their statements. Non-transactional migrations, and statements that cannot run in a
+// transaction, are skipped.
+func dryRunExecute(ctx context.Context, conf *config.Config) error {
+	results, err := engine.DryRunMigrations(ctx, conf, slog.Default())
+	if err == nil && len(results) == 0 {
+		slog.Info("no pending migrations")
+		return nil
+	}
+	skipped, skippedStatements := 0, 0
+	for _, r := range results {
+		if r.Repeatable {
+			fmt.Printf("\nRepeatable migration %s:\n\n", r.Filename)
+		} else {
+			fmt.Printf("\nMigration %s:\n\n", r.Version)
+		}
+		printStatementResults(r.Statements)
+		if r.Skipped {
+			skipped++
+			slog.Warn("skipped non-transactional migration, later migrations depending on it may fail", "version", r.Version, "file", r.Filename)
+			continue
+		}
+		for _, stmt := range r.Statements {
+			if stmt.Skipped {
+				skippedStatements++
+				slog.Warn("skipped statement that cannot run in a transaction, later statements depending on it may fail",
+					"version", r.Version, "file", r.Filename, "line", stmt.Line)
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	slog.Info("executed migrations and rolled them back", "executed", len(results)-skipped, "skipped", skipped, "skipped_statements", skippedStatements)
+	return nil
+}
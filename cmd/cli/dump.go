@@ -0,0 +1,290 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cortea-ai/pg-migrant/internal/config"
+	"github.com/cortea-ai/pg-migrant/internal/db"
+	"github.com/cortea-ai/pg-migrant/internal/diffutils"
+	"github.com/cortea-ai/pg-migrant/internal/engine"
+	"github.com/cortea-ai/pg-migrant/internal/sqlparse"
+	"github.com/stripe/pg-schema-diff/pkg/diff"
+)
+
+// Layouts of the files written by DumpSchema.
+const (
+	LayoutSchema = "schema"
+	LayoutTable  = "table"
+)
+
+// DumpSchema introspects the configured database and writes its schema as declarative files under dir,
+// either one file per schema or one file per table. It prints the schema_files setting listing the
+// files in an order they can be applied in. Other .sql files under dir are reported as stale, and
+// removed if prune is set.
+func DumpSchema(ctx context.Context, conf *config.Config, dir, layout string, prune bool) error {
+	var group func(diff.Plan) []schemaFile
+	switch layout {
+	case LayoutSchema:
+		group = groupBySchema
+	case LayoutTable:
+		group = groupByTable
+	default:
+		return fmt.Errorf("unknown layout %q: must be %q or %q", layout, LayoutSchema, LayoutTable)
+	}
+
+	plan, err := introspectSchema(ctx, conf)
+	if err != nil {
+		return err
+	}
+	files := group(plan)
+	paths, err := writeSchemaFiles(dir, files)
+	if err != nil {
+		return err
+	}
+
+	stale, err := staleSchemaFiles(dir, paths)
+	if err != nil {
+		return err
+	}
+	for _, path := range stale {
+		if !prune {
+			slog.Warn("stale schema file, not part of the database schema", "path", path)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		slog.Info("removed stale schema file", "path", path)
+	}
+
+	slog.Info("dumped schema", "statements", len(plan.Statements), "files", len(files), "dir", dir)
+	// The schema files are listed in the order they must be listed in the config.
+	fmt.Println("schema_files = [")
+	for _, path := range paths {
+		fmt.Printf("  %q,\n", filepath.ToSlash(path))
+	}
+	fmt.Println("]")
+	return nil
+}
+
+// staleSchemaFiles returns the .sql files under dir that are not in written, sorted.
+func staleSchemaFiles(dir string, written []string) ([]string, error) {
+	keep := make(map[string]bool, len(written))
+	for _, path := range written {
+		keep[filepath.Clean(path)] = true
+	}
+	var stale []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.ToLower(filepath.Ext(path)) == ".sql" && !keep[filepath.Clean(path)] {
+			stale = append(stale, path)
+		}
+		return nil
+	})
+	sort.Strings(stale)
+	return stale, err
+}
+
+// schemaFile is a declarative schema file generated from an introspected database.
+type schemaFile struct {
+	// Path is relative to the directory the schema files are written to.
+	Path       string
+	Statements []diff.Statement
+}
+
+// introspectSchema returns the plan creating the schema of the configured database from scratch, by
+// diffing an empty temp database against it.
+func introspectSchema(ctx context.Context, conf *config.Config) (diff.Plan, error) {
+	tempDbFactory, err := engine.NewTempDbFactory(ctx, conf)
+	if err != nil {
+		return diff.Plan{}, err
+	}
+	defer engine.CloseTempDbFactory(slog.Default(), tempDbFactory)
+
+	emptyDb, err := tempDbFactory.Create(ctx)
+	if err != nil {
+		return diff.Plan{}, fmt.Errorf("creating temp database: %w", err)
+	}
+	defer engine.CloseTempDb(ctx, slog.Default(), emptyDb)
+
+	conn, err := db.NewConn(ctx, conf.GetDBUrl())
+	if err != nil {
+		return diff.Plan{}, err
+	}
+	defer conn.Close(ctx)
+
+	schemaOpts := append(engine.SchemaFilterOpts(conf), emptyDb.ExcludeMetadataOptions...)
+
+	return diff.Generate(ctx, emptyDb.ConnPool, diff.DBSchemaSource(conn),
+		diff.WithGetSchemaOpts(schemaOpts...),
+		diff.WithTempDbFactory(tempDbFactory),
+	)
+}
+
+// groupBySchema splits a plan into one file per schema, in a directory named after the schema, plus one
+// for extensions. Files are ordered by the first statement they contain, extensions first, so that
+// listing them in order in schema_files preserves the order of the plan as far as possible.
+func groupBySchema(plan diff.Plan) []schemaFile {
+	var files []*schemaFile
+	byPath := make(map[string]*schemaFile)
+	var prev *schemaFile
+	for _, stmt := range plan.Statements {
+		var path string
+		switch obj := sqlparse.ParseObject(stmt.DDL); obj.Kind {
+		case sqlparse.KindExtension:
+			path = "extensions.sql"
+		case sqlparse.KindUnknown:
+			// Keep statements we cannot attribute next to the statement they follow.
+			if prev != nil {
+				path = prev.Path
+			} else {
+				path = "schema.sql"
+			}
+		default:
+			path = filepath.Join(fileName(obj.Schema), "schema.sql")
+		}
+		file, ok := byPath[path]
+		if !ok {
+			file = &schemaFile{Path: path}
+			byPath[path] = file
+			files = append(files, file)
+		}
+		file.Statements = append(file.Statements, stmt)
+		prev = file
+	}
+
+	var ordered []schemaFile
+	if extensions, ok := byPath["extensions.sql"]; ok {
+		ordered = append(ordered, *extensions)
+	}
+	for _, file := range files {
+		if file.Path != "extensions.sql" {
+			ordered = append(ordered, *file)
+		}
+	}
+	return ordered
+}
+
+// Categories of the files written by groupByTable, in the order the files must be applied in.
+const (
+	categoryExtensions = iota
+	categorySchemas
+	categoryTypes
+	categorySequences
+	categoryFunctions
+	categoryTables
+	categoryViews
+	categoryForeignKeys
+)
+
+// groupByTable splits a plan into one file per table, holding its indexes, triggers, policies and
+// owned sequences too, and one file per schema for each other kind of object. Foreign keys go to a file
+// per schema applied last, so that tables can be listed in any order. Files are ordered by category,
+// then by the first statement they contain.
+func groupByTable(plan diff.Plan) []schemaFile {
+	type categorizedFile struct {
+		schemaFile
+		category int
+	}
+	var files []*categorizedFile
+	byPath := make(map[string]*categorizedFile)
+	var prev *categorizedFile
+	for _, stmt := range plan.Statements {
+		var path string
+		var category int
+		obj := sqlparse.ParseObject(stmt.DDL)
+		switch obj.Kind {
+		case sqlparse.KindExtension:
+			path, category = "extensions.sql", categoryExtensions
+		case sqlparse.KindSchema:
+			path, category = filepath.Join(fileName(obj.Name), "schema.sql"), categorySchemas
+		case sqlparse.KindType:
+			path, category = filepath.Join(fileName(obj.Schema), "types.sql"), categoryTypes
+		case sqlparse.KindSequence:
+			path, category = filepath.Join(fileName(obj.Schema), "sequences.sql"), categorySequences
+		case sqlparse.KindFunction:
+			path, category = filepath.Join(fileName(obj.Schema), "functions.sql"), categoryFunctions
+		case sqlparse.KindView:
+			path, category = filepath.Join(fileName(obj.Schema), "views.sql"), categoryViews
+		case sqlparse.KindTable:
+			// Constraints are validated along with the foreign keys, as they may be foreign keys themselves.
+			if sqlparse.HasKeywords(stmt.DDL, "FOREIGN", "KEY") || sqlparse.HasKeywords(stmt.DDL, "VALIDATE", "CONSTRAINT") {
+				path, category = filepath.Join(fileName(obj.Schema), "foreign_keys.sql"), categoryForeignKeys
+			} else {
+				path, category = filepath.Join(fileName(obj.Schema), "tables", fileName(obj.Name)+".sql"), categoryTables
+			}
+		default:
+			// Keep statements we cannot attribute next to the statement they follow.
+			if prev != nil {
+				path, category = prev.Path, prev.category
+			} else {
+				path, category = "schema.sql", categorySchemas
+			}
+		}
+		file, ok := byPath[path]
+		if !ok {
+			file = &categorizedFile{schemaFile: schemaFile{Path: path}, category: category}
+			byPath[path] = file
+			files = append(files, file)
+		}
+		file.Statements = append(file.Statements, stmt)
+		prev = file
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].category < files[j].category
+	})
+	ordered := make([]schemaFile, 0, len(files))
+	for _, file := range files {
+		ordered = append(ordered, file.schemaFile)
+	}
+	return ordered
+}
+
+// fileName makes an object name safe to use as a file name. Quoted identifiers may hold anything, so
+// path separators are replaced and names such as ".." cannot point out of the directory.
+func fileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', 0:
+			return '_'
+		}
+		return r
+	}, name)
+	if strings.Trim(name, ".") == "" {
+		return strings.Repeat("_", len(name)+1)
+	}
+	return name
+}
+
+// writeSchemaFiles writes the files under dir and returns their paths.
+func writeSchemaFiles(dir string, files []schemaFile) ([]string, error) {
+	var paths []string
+	for _, file := range files {
+		if !filepath.IsLocal(file.Path) {
+			return nil, fmt.Errorf("schema file %q is not within %s", file.Path, dir)
+		}
+		path := filepath.Join(dir, file.Path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		stmts := make([]string, 0, len(file.Statements))
+		for _, stmt := range file.Statements {
+			stmts = append(stmts, diffutils.StatementToSQL(stmt))
+		}
+		if err := os.WriteFile(path, []byte(strings.Join(stmts, "\n\n")+"\n"), 0644); err != nil {
+			return nil, fmt.Errorf("writing schema file: %w", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
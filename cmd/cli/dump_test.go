@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"users", "users"},
+		{"Order Items", "Order Items"},
+		{"a/b", "a_b"},
+		{`..\..\etc`, ".._.._etc"},
+		{"../../etc/cron.d", ".._.._etc_cron.d"},
+		{"..", "___"},
+		{".", "__"},
+		{"..users", "..users"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fileName(tt.name)
+			if got != tt.want {
+				t.Errorf("fileName() = %q, want %q", got, tt.want)
+			}
+			if !filepath.IsLocal(got) || filepath.Base(got) != got {
+				t.Errorf("fileName() = %q, want a single local path element", got)
+			}
+		})
+	}
+}
+
+func TestWriteSchemaFilesOutsideDir(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := writeSchemaFiles(filepath.Join(dir, "schema"), []schemaFile{{Path: "../outside.sql"}}); err == nil {
+		t.Errorf("writeSchemaFiles() succeeded, want an error for a path outside of the directory")
+	}
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/cortea-ai/pg-migrant/internal/config"
+	"github.com/cortea-ai/pg-migrant/internal/db"
+	"github.com/cortea-ai/pg-migrant/internal/engine"
+)
+
+// MarkApplied records a migration as applied without running it. Marking a version moves the current
+// version past it, so it is refused while an earlier migration is pending unless force is set: apply
+// would skip that migration.
+func MarkApplied(ctx context.Context, conf *config.Config, version, note string, force bool) error {
+	if err := engine.ValidateVersion(version); err != nil {
+		return err
+	}
+	migrations, err := engine.LoadMigrations(conf.GetFS(), conf.GetMigrationDir())
+	if err != nil {
+		return err
+	}
+	migration, err := findMigration(migrations, version)
+	if err != nil {
+		return err
+	}
+	conn, currentVersion, err := db.NewConnEnsureVersionTable(ctx, conf.GetDBUrl())
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	for _, m := range engine.PendingAfter(currentVersion, migrations) {
+		if m.Version >= version {
+			break
+		}
+		if !force {
+			return fmt.Errorf("migration %s is pending and would be skipped by apply once %s is marked: "+
+				"apply or mark it first, or pass --force", m.Version, version)
+		}
+		slog.Warn("skipping pending migration", "version", m.Version)
+	}
+	if err := promptForApproval(fmt.Sprintf("Mark migration %s as applied without running it?", version)); err != nil {
+		return err
+	}
+	if err := conn.MarkApplied(ctx, version, migration.Content, note); err != nil {
+		return err
+	}
+	slog.Info("marked migration as applied", "version", version)
+	return nil
+}
+
+func Unmark(ctx context.Context, conf *config.Config, version, note string) error {
+	if err := engine.ValidateVersion(version); err != nil {
+		return err
+	}
+	migrations, err := engine.LoadMigrations(conf.GetFS(), conf.GetMigrationDir())
+	if err != nil {
+		return err
+	}
+	if _, err := findMigration(migrations, version); err != nil {
+		return err
+	}
+	conn, _, err := db.NewConnEnsureVersionTable(ctx, conf.GetDBUrl())
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	if err := promptForApproval(fmt.Sprintf("Remove migration %s from the migration history?", version)); err != nil {
+		return err
+	}
+	if err := conn.Unmark(ctx, version, note); err != nil {
+		return err
+	}
+	slog.Info("unmarked migration", "version", version)
+	return nil
+}
+
+func Repair(ctx context.Context, conf *config.Config, note string) error {
+	migrations, err := engine.LoadMigrations(conf.GetFS(), conf.GetMigrationDir())
+	if err != nil {
+		return err
+	}
+	checksums := make(map[string]string, len(migrations))
+	for _, m := range migrations {
+		checksums[m.Version] = db.Checksum(m.Content)
+	}
+	conn, _, err := db.NewConnEnsureVersionTable(ctx, conf.GetDBUrl())
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	if err := promptForApproval("Recompute checksums and remove failed migrations from the history?"); err != nil {
+		return err
+	}
+	result, err := conn.Repair(ctx, checksums, note)
+	if err != nil {
+		return err
+	}
+	for _, version := range result.Updated {
+		slog.Info("updated checksum of migration", "version", version)
+	}
+	for _, version := range result.Removed {
+		slog.Info("removed failed migration", "version", version)
+	}
+	slog.Info("repaired migration history")
+	return nil
+}
+
+// findMigration returns the migration with the given version.
+func findMigration(migrations []engine.Migration, version string) (engine.Migration, error) {
+	for _, m := range migrations {
+		if m.Version == version {
+			return m, nil
+		}
+	}
+	return engine.Migration{}, fmt.Errorf("migration %s not found", version)
+}
@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/cortea-ai/pg-migrant/internal/config"
+	"github.com/cortea-ai/pg-migrant/internal/db"
+	"github.com/cortea-ai/pg-migrant/internal/diffutils"
+	"github.com/cortea-ai/pg-migrant/internal/engine"
+)
+
+const (
+	configFilename  = "pg-migrant.hcl"
+	schemaDirname   = "schema"
+	migrationsDir   = "migrations"
+	initialSchema   = "schema.sql"
+	initialBaseline = "0000" + engine.BaselineSuffix + ".sql"
+)
+
+var configTemplate = template.Must(template.New(configFilename).Parse(`variable "postgres_user" {
+  default = getenv("PGUSER", "{{.User}}")
+}
+variable "postgres_password" {
+  type = string
+  sensitive = true
+  default = getenv("PGPASSWORD")
+}
+variable "postgres_host" {
+  default = getenv("PGHOST", "{{.Host}}")
+}
+variable "postgres_port" {
+  type = number
+  default = getenv("PGPORT", "{{.Port}}")
+}
+variable "postgres_dbname" {
+  default = getenv("PGDATABASE", "{{.DBName}}")
+}
+
+locals {
+  db_url = "postgres://${var.postgres_user}:${var.postgres_password}@${var.postgres_host}:${var.postgres_port}/${var.postgres_dbname}"
+}
+
+default_env = "dev"
+
+defaults {
+  // Files, directories or glob patterns, sorted by the objects they reference.
+  schema_files = [
+{{- range .SchemaFiles}}
+    "{{.}}",
+{{- end}}
+  ]
+  migration_dir = "{{.MigrationDir}}"
+}
+
+env "dev" {
+  db_url = "${local.db_url}?sslmode=disable"
+  allow_db_clean = true
+}
+
+env "prod" {
+  db_url = "${local.db_url}?sslmode=require"
+}
+`))
+
+type configTemplateData struct {
+	User, Host, Port, DBName string
+	SchemaFiles              []string
+	MigrationDir             string
+}
+
+// Init scaffolds a project in dir. If fromDB is set, the schema files and a baseline migration are
+// generated from that database, and the baseline is recorded as applied on it.
+func Init(ctx context.Context, dir, fromDB string) error {
+	configPath := filepath.Join(dir, configFilename)
+	if _, err := os.Stat(configPath); err == nil {
+		return fmt.Errorf("%s already exists", configPath)
+	}
+
+	data := configTemplateData{
+		User:         "postgres",
+		Host:         "localhost",
+		Port:         "5432",
+		DBName:       "postgres",
+		MigrationDir: migrationsDir,
+	}
+	if err := os.MkdirAll(filepath.Join(dir, migrationsDir), 0755); err != nil {
+		return err
+	}
+
+	var baseline string
+	if fromDB != "" {
+		var err error
+		if baseline, err = initFromDB(ctx, dir, fromDB, &data); err != nil {
+			return err
+		}
+	}
+	if len(data.SchemaFiles) == 0 {
+		if err := os.MkdirAll(filepath.Join(dir, schemaDirname), 0755); err != nil {
+			return err
+		}
+		schemaPath := filepath.Join(dir, schemaDirname, initialSchema)
+		if err := os.WriteFile(schemaPath, []byte("-- Declare the desired schema here, e.g. CREATE TABLE statements.\n"), 0644); err != nil {
+			return err
+		}
+		data.SchemaFiles = []string{filepath.ToSlash(filepath.Join(schemaDirname, initialSchema))}
+	}
+
+	var buf bytes.Buffer
+	if err := configTemplate.Execute(&buf, data); err != nil {
+		return err
+	}
+	if err := os.WriteFile(configPath, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	slog.Info("initialized pg-migrant project", "config", configPath)
+
+	if fromDB == "" {
+		return nil
+	}
+	conn, _, err := db.NewConnEnsureVersionTable(ctx, fromDB)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	if err := promptForApproval(fmt.Sprintf("Record %s as applied on the database?", initialBaseline)); err != nil {
+		return err
+	}
+	if err := conn.MarkApplied(ctx, "0000", baseline, "pg-migrant init"); err != nil {
+		return err
+	}
+	slog.Info("recorded migration as applied", "version", initialBaseline)
+	return nil
+}
+
+// initFromDB writes the schema files and the baseline migration of the database, and returns the baseline.
+func initFromDB(ctx context.Context, dir, fromDB string, data *configTemplateData) (string, error) {
+	if u, err := url.Parse(fromDB); err == nil {
+		if user := u.User.Username(); user != "" {
+			data.User = user
+		}
+		if host := u.Hostname(); host != "" {
+			data.Host = host
+		}
+		if port := u.Port(); port != "" {
+			data.Port = port
+		}
+		if dbname := strings.TrimPrefix(u.Path, "/"); dbname != "" {
+			data.DBName = dbname
+		}
+	}
+
+	conf := &config.Config{SelectedEnv: config.Env{DBUrl: fromDB}}
+	plan, err := introspectSchema(ctx, conf)
+	if err != nil {
+		return "", err
+	}
+
+	paths, err := writeSchemaFiles(filepath.Join(dir, schemaDirname), groupBySchema(plan))
+	if err != nil {
+		return "", err
+	}
+	for _, path := range paths {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return "", err
+		}
+		data.SchemaFiles = append(data.SchemaFiles, filepath.ToSlash(rel))
+	}
+
+	baseline := "-- Baseline of the existing database schema\n\n" + diffutils.PlanToPrettyS(plan)
+	if len(plan.Statements) == 0 {
+		baseline = "-- Baseline of the existing database schema, which was empty\n"
+	}
+	if err := os.WriteFile(filepath.Join(dir, migrationsDir, initialBaseline), []byte(baseline), 0644); err != nil {
+		return "", err
+	}
+	return baseline, nil
+}
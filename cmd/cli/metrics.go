@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/cortea-ai/pg-migrant/internal/config"
+	"github.com/cortea-ai/pg-migrant/internal/db"
+	"github.com/cortea-ai/pg-migrant/internal/engine"
+	"github.com/cortea-ai/pg-migrant/internal/metrics"
+)
+
+// pushTimeout bounds a push to the Pushgateway, which should not hold up a deploy.
+const pushTimeout = 10 * time.Second
+
+// Metrics records the pending and applied migrations of the database as gauges, for cron-based
+// exporters. They are exported to the destinations of the metrics block, or printed if it has none.
+func Metrics(ctx context.Context, conf *config.Config) error {
+	report, err := engine.Status(ctx, conf, slog.Default())
+	if err != nil {
+		return err
+	}
+	reg := metrics.New("env", conf.SelectedEnv.Name)
+	reg.Set(metrics.PendingMigrations, float64(len(report.Migrations)+len(report.Repeatables)))
+	applied, failed := 0, 0
+	for _, e := range report.History {
+		if e.Status == db.StatusFailed {
+			failed++
+		} else {
+			applied++
+		}
+	}
+	reg.Set(metrics.AppliedMigrations, float64(applied))
+	reg.Set(metrics.FailedMigrations, float64(failed))
+	reg.Set(metrics.CurrentVersion, 1, "version", report.CurrentVersion)
+	if !conf.GetMetricsConfig().Enabled() {
+		reg.Set(metrics.LastRun, float64(time.Now().Unix()))
+		_, err := reg.WriteTo(os.Stdout)
+		return err
+	}
+	return exportMetrics(ctx, conf, reg)
+}
+
+// exportMetrics writes the metrics to the textfile and pushes them to the Pushgateway of the metrics
+// block, grouped by env.
+func exportMetrics(ctx context.Context, conf *config.Config, reg *metrics.Registry) error {
+	metricsConf := conf.GetMetricsConfig()
+	reg.Set(metrics.LastRun, float64(time.Now().Unix()))
+	if metricsConf.Textfile != "" {
+		if err := reg.WriteTextfile(metricsConf.Textfile); err != nil {
+			return err
+		}
+		slog.Debug("wrote metrics file", "path", metricsConf.Textfile)
+	}
+	if metricsConf.PushURL != "" {
+		// The push must happen even when the run was interrupted, to report it.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), pushTimeout)
+		defer cancel()
+		if err := reg.Push(ctx, http.DefaultClient, metricsConf.PushURL, metricsConf.GetJob(), "env", conf.SelectedEnv.Name); err != nil {
+			return err
+		}
+		slog.Debug("pushed metrics", "job", metricsConf.GetJob())
+	}
+	return nil
+}
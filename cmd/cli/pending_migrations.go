@@ -3,13 +3,11 @@ package cli
 import (
 	"context"
 	"fmt"
-	"os"
-	"path/filepath"
-	"strconv"
-	"strings"
+	"io/fs"
 
 	"github.com/cortea-ai/pg-migrant/internal/config"
 	"github.com/cortea-ai/pg-migrant/internal/db"
+	"github.com/cortea-ai/pg-migrant/internal/engine"
 )
 
 func PendingMigrations(ctx context.Context, conf *config.Config) error {
@@ -18,67 +16,37 @@ func PendingMigrations(ctx context.Context, conf *config.Config) error {
 		return err
 	}
 	defer conn.Close(ctx)
-	versions, _, err := findPendingMigrations(currentVersion, conf.GetMigrationDir())
+	migrations, err := findPendingMigrations(conf.GetFS(), currentVersion, conf.GetMigrationDir())
 	if err != nil {
 		return err
 	}
-	if len(versions) == 0 {
-		println("No pending migrations")
-		return nil
-	}
-	println("Pending migrations:")
-	for _, version := range versions {
-		println(">", version)
-	}
-	return nil
-}
-
-func findPendingMigrations(currentVersion string, migrationDir string) ([]string, []string, error) {
-	files, err := os.ReadDir(migrationDir)
+	repeatables, err := engine.PendingRepeatables(ctx, conn, conf.GetFS(), conf.GetRepeatableDir())
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
-	var versions []string
-	var migrations []string
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-		version, err := VersionFromFilename(file.Name())
-		if err != nil {
-			return nil, nil, err
-		}
-		if version > currentVersion {
-			content, err := os.ReadFile(filepath.Join(migrationDir, file.Name()))
-			if err != nil {
-				return nil, nil, err
-			}
-			versions = append(versions, version)
-			migrations = append(migrations, string(content))
-		}
+	if len(migrations) == 0 && len(repeatables) == 0 {
+		fmt.Println("No pending migrations")
+		return nil
 	}
-	return versions, migrations, nil
-}
-
-func VersionFromFilename(filename string) (string, error) {
-	filenameWithoutExt := strings.TrimSuffix(filename, filepath.Ext(filename))
-	parts := strings.Split(filenameWithoutExt, "_")
-	if len(parts) == 0 {
-		return "", fmt.Errorf("invalid filename: %s", filename)
+	if len(migrations) > 0 {
+		fmt.Println("Pending migrations:")
+		for _, m := range migrations {
+			fmt.Println(">", m.Version)
+		}
 	}
-	if err := ValidateVersion(parts[0]); err != nil {
-		return "", err
+	if len(repeatables) > 0 {
+		fmt.Println("Pending repeatable migrations:")
+		for _, r := range repeatables {
+			fmt.Println(">", r.Filename)
+		}
 	}
-	return parts[0], nil
+	return nil
 }
 
-func ValidateVersion(version string) error {
-	if len(version) != 4 {
-		return fmt.Errorf("version must be 4 characters long: %s", version)
-	}
-	_, err := strconv.Atoi(version)
+func findPendingMigrations(fsys fs.FS, currentVersion string, migrationDir string) ([]engine.Migration, error) {
+	migrations, err := engine.LoadMigrations(fsys, migrationDir)
 	if err != nil {
-		return fmt.Errorf("version must be numeric: %s", version)
+		return nil, err
 	}
-	return nil
+	return engine.PendingAfter(currentVersion, migrations), nil
 }
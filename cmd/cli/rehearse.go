@@ -0,0 +1,253 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cortea-ai/pg-migrant/internal/config"
+	"github.com/cortea-ai/pg-migrant/internal/db"
+	"github.com/cortea-ai/pg-migrant/internal/engine"
+	"github.com/cortea-ai/pg-migrant/internal/sqlparse"
+	"github.com/jackc/pgx/v5"
+	"github.com/stripe/pg-schema-diff/pkg/tempdb"
+)
+
+// lockSampleInterval is how often pg_locks is sampled while a statement runs. Locks held for less time
+// may be missed.
+const lockSampleInterval = 5 * time.Millisecond
+
+// Rehearse applies the pending migrations to a temp database holding a copy of the schema of the
+// configured database, and, if sampleRows is positive, up to that many rows of each of its tables. It
+// reports the duration of each statement, the locks it took and whether it failed. The configured
+// database is only read.
+func Rehearse(ctx context.Context, conf *config.Config, sampleRows int) error {
+	conn, err := db.NewConn(ctx, conf.GetDBUrl())
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	currentVersion, err := conn.CheckCurrentVersion(ctx)
+	if err != nil && !errors.Is(err, db.ErrTableNotFound) {
+		return err
+	}
+	all, err := engine.LoadMigrations(conf.GetFS(), conf.GetMigrationDir())
+	if err != nil {
+		return err
+	}
+	if err := engine.ResolveBaseline(ctx, conn, slog.Default(), currentVersion, all, true); err != nil {
+		return err
+	}
+	migrations := engine.PendingAfter(currentVersion, all)
+	repeatables, err := engine.PendingRepeatables(ctx, conn, conf.GetFS(), conf.GetRepeatableDir())
+	if err != nil {
+		return err
+	}
+	if len(migrations) == 0 && len(repeatables) == 0 {
+		slog.Info("no pending migrations", "version", currentVersion)
+		return nil
+	}
+
+	plan, err := introspectSchema(ctx, conf)
+	if err != nil {
+		return err
+	}
+	tempDbFactory, err := engine.NewTempDbFactory(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer engine.CloseTempDbFactory(slog.Default(), tempDbFactory)
+	rehearsalDb, err := tempDbFactory.Create(ctx)
+	if err != nil {
+		return fmt.Errorf("creating temp database: %w", err)
+	}
+	defer engine.CloseTempDb(ctx, slog.Default(), rehearsalDb)
+	for _, stmt := range plan.Statements {
+		if _, err := rehearsalDb.ConnPool.ExecContext(ctx, stmt.DDL); err != nil {
+			return fmt.Errorf("cloning schema: %w", err)
+		}
+	}
+	slog.Info("cloned the schema of the database into a temp database", "version", currentVersion)
+
+	if sampleRows > 0 {
+		if err := sampleData(ctx, conf, conn, rehearsalDb, sampleRows); err != nil {
+			return err
+		}
+	}
+
+	for i, m := range migrations {
+		fmt.Printf("\nMigration %s as %d of %d migrations:\n\n", m.Version, i+1, len(migrations))
+		results, err := rehearseMigration(ctx, rehearsalDb, m)
+		printStatementResults(results)
+		engine.LogStatementResults(slog.Default(), m, results)
+		if err != nil {
+			return fmt.Errorf("rehearsal of migration %s failed: %w", m.Filename, err)
+		}
+	}
+	for i, r := range repeatables {
+		fmt.Printf("\nRepeatable migration %s as %d of %d repeatable migrations:\n\n", r.Filename, i+1, len(repeatables))
+		results, err := rehearseMigration(ctx, rehearsalDb, r)
+		printStatementResults(results)
+		engine.LogStatementResults(slog.Default(), r, results)
+		if err != nil {
+			return fmt.Errorf("rehearsal of repeatable migration %s failed: %w", r.Filename, err)
+		}
+	}
+	slog.Info("rehearsed migrations", "count", len(migrations)+len(repeatables))
+	return nil
+}
+
+// sampleData copies up to sampleRows rows of each table of the compared schemas into the temp database.
+// Tables that cannot be copied are reported and skipped.
+func sampleData(ctx context.Context, conf *config.Config, conn *db.Conn, tempDb *tempdb.Database, sampleRows int) error {
+	schemas, err := engine.IntrospectedSchemas(ctx, conf, conn)
+	if err != nil {
+		return err
+	}
+	tables, err := conn.ListTables(ctx, schemas)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, table := range tables {
+		rows, err := db.CopyRows(ctx, conn.DB, tempDb.ConnPool, table, sampleRows)
+		if err != nil {
+			slog.Warn("skipped sampling table", "table", table.String(), "error", err)
+			continue
+		}
+		total += rows
+	}
+	slog.Info("sampled rows", "rows", total, "tables", len(tables))
+	return nil
+}
+
+// rehearseMigration runs the statements of a migration one by one on a single connection, in a
+// transaction unless the migration is non-transactional, while sampling the locks they take. A Go
+// migration is reported as a single statement.
+func rehearseMigration(ctx context.Context, tempDb *tempdb.Database, m engine.Migration) ([]engine.StatementResult, error) {
+	conn, err := tempDb.ConnPool.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var pid int
+	if err := conn.QueryRowContext(ctx, "SELECT pg_backend_pid()").Scan(&pid); err != nil {
+		return nil, err
+	}
+
+	if m.Func != nil {
+		sampler := startLockSampler(ctx, tempDb.ConnPool, pid)
+		start := time.Now()
+		err := db.InPgxTx(ctx, conn, func(tx pgx.Tx) error { return m.Func(ctx, tx) })
+		return []engine.StatementResult{{SQL: m.Content, Duration: time.Since(start), Locks: sampler.stop(), Err: err}}, err
+	}
+
+	var e interface {
+		ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	} = conn
+	var tx *sql.Tx
+	if !db.IsNonTransactional(m.Content) {
+		if tx, err = conn.BeginTx(ctx, nil); err != nil {
+			return nil, err
+		}
+		defer tx.Rollback() // No-op if committed successfully
+		e = tx
+	}
+
+	var results []engine.StatementResult
+	seen := make(map[string]bool)
+	for _, stmt := range sqlparse.Split(m.Content) {
+		sampler := startLockSampler(ctx, tempDb.ConnPool, pid)
+		start := time.Now()
+		res, err := e.ExecContext(ctx, stmt.SQL)
+		result := engine.StatementResult{Line: stmt.Line, SQL: stmt.SQL, Duration: time.Since(start), Err: err}
+		if err == nil {
+			result.RowsAffected, _ = res.RowsAffected()
+		}
+		for _, lock := range sampler.stop() {
+			if !seen[lock] {
+				seen[lock] = true
+				result.Locks = append(result.Locks, lock)
+			}
+		}
+		results = append(results, result)
+		if err != nil {
+			return results, err
+		}
+	}
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			return results, fmt.Errorf("committing transaction: %w", err)
+		}
+	}
+	return results, nil
+}
+
+type lockSampler struct {
+	done  chan struct{}
+	wg    sync.WaitGroup
+	locks map[string]bool
+}
+
+// startLockSampler polls the relation locks granted to the backend pid until stopped.
+func startLockSampler(ctx context.Context, pool *sql.DB, pid int) *lockSampler {
+	s := &lockSampler{done: make(chan struct{}), locks: make(map[string]bool)}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(lockSampleInterval)
+		defer ticker.Stop()
+		for {
+			s.sample(ctx, pool, pid)
+			select {
+			case <-s.done:
+				s.sample(ctx, pool, pid)
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s
+}
+
+func (s *lockSampler) sample(ctx context.Context, pool *sql.DB, pid int) {
+	rows, err := pool.QueryContext(ctx, `
+		SELECT l.mode, n.nspname, c.relname
+		FROM pg_catalog.pg_locks l
+		JOIN pg_catalog.pg_class c ON c.oid = l.relation
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE l.pid = $1 AND l.locktype = 'relation' AND l.granted
+			AND l.database = (SELECT oid FROM pg_catalog.pg_database WHERE datname = current_database())
+			AND n.nspname <> 'information_schema' AND n.nspname !~ '^pg_';`, pid)
+	if err != nil {
+		// Sampling is best effort: a missed sample only hides locks.
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var mode, schema, name string
+		if err := rows.Scan(&mode, &schema, &name); err == nil {
+			s.locks[fmt.Sprintf("%s on %s.%s", mode, schema, name)] = true
+		}
+	}
+}
+
+// stop takes a last sample, as locks taken in a transaction are held until it ends, and returns the
+// locks seen, sorted.
+func (s *lockSampler) stop() []string {
+	close(s.done)
+	s.wg.Wait()
+	locks := make([]string, 0, len(s.locks))
+	for lock := range s.locks {
+		locks = append(locks, lock)
+	}
+	sort.Strings(locks)
+	return locks
+}
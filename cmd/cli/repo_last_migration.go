@@ -2,13 +2,30 @@ package cli
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/cortea-ai/pg-migrant/internal/config"
+	"github.com/cortea-ai/pg-migrant/internal/engine"
 	"github.com/google/go-github/github"
 	"golang.org/x/oauth2"
 )
 
 func RepoLastMigration(ctx context.Context, conf *config.Config, token string) error {
+	currentVersion, err := repoLastVersion(ctx, conf, token)
+	if err != nil {
+		return err
+	}
+	if currentVersion != "" {
+		fmt.Println("Current version:", currentVersion)
+	} else {
+		fmt.Println("No migrations applied yet")
+	}
+	return nil
+}
+
+// repoLastVersion returns the version of the last migration on the remote
+// target branch, or an empty string if there is none.
+func repoLastVersion(ctx context.Context, conf *config.Config, token string) (string, error) {
 	tc := oauth2.NewClient(ctx, oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
 	))
@@ -23,22 +40,13 @@ func RepoLastMigration(ctx context.Context, conf *config.Config, token string) e
 		nil,
 	)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// Get latest version from remote migrations
-	var currentVersion string
-	if len(migrations) > 0 {
-		lastMigration := migrations[len(migrations)-1]
-		currentVersion, err = VersionFromFilename(lastMigration.GetName())
-		if err != nil {
-			return err
-		}
+	if len(migrations) == 0 {
+		return "", nil
 	}
-	if currentVersion != "" {
-		println("Current version:", currentVersion)
-	} else {
-		println("No migrations applied yet")
-	}
-	return nil
+	lastMigration := migrations[len(migrations)-1]
+	return engine.VersionFromFilename(lastMigration.GetName())
 }
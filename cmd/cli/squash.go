@@ -2,98 +2,188 @@ package cli
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 
 	"github.com/cortea-ai/pg-migrant/internal/config"
-	"github.com/google/go-github/github"
-	"golang.org/x/oauth2"
+	"github.com/cortea-ai/pg-migrant/internal/engine"
+	"github.com/cortea-ai/pg-migrant/internal/migrate"
+	"github.com/cortea-ai/pg-migrant/internal/sqlparse"
+	"github.com/stripe/pg-schema-diff/pkg/diff"
+	"github.com/stripe/pg-schema-diff/pkg/schema"
+	"github.com/stripe/pg-schema-diff/pkg/tempdb"
 )
 
-func Squash(ctx context.Context, conf *config.Config, token string) error {
-	tc := oauth2.NewClient(ctx, oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: token},
-	))
-
-	ghClient := github.NewClient(tc)
-
-	_, migrations, _, err := ghClient.Repositories.GetContents(
-		ctx,
-		conf.GetGitHubConfig().Owner,
-		conf.GetGitHubConfig().Repo,
-		conf.GetMigrationDir(),
-		nil,
-	)
+func Squash(ctx context.Context, conf *config.Config, token string, regenerate, discardDataChanges bool) error {
+	currentVersion, err := repoLastVersion(ctx, conf, token)
 	if err != nil {
 		return err
 	}
 
-	// Get latest version from remote migrations
-	var currentVersion string
-	if len(migrations) > 0 {
-		lastMigration := migrations[len(migrations)-1]
-		currentVersion, err = VersionFromFilename(lastMigration.GetName())
-		if err != nil {
-			return err
-		}
-	}
-
-	_, pendingMigrations, err := findPendingMigrations(currentVersion, conf.GetMigrationDir())
+	migrations, err := engine.ReadMigrations(conf.GetFS(), conf.GetMigrationDir())
 	if err != nil {
 		return err
 	}
 
-	if len(pendingMigrations) == 0 {
+	pending := engine.PendingAfter(currentVersion, migrations)
+	applied := migrations[:len(migrations)-len(pending)]
+
+	if len(pending) == 0 {
 		return nil
 	}
-
-	// Get all migration files after current version
-	files, err := os.ReadDir(conf.GetMigrationDir())
-	if err != nil {
-		return err
+	// Squashed migrations take the first pending version, which would move them before Go migrations.
+	for _, m := range migrate.Registered() {
+		if m.Version > currentVersion {
+			return fmt.Errorf("cannot squash migrations while Go migration %s (%s) is pending", m.Version, m.Name)
+		}
 	}
 
-	var pendingFiles []string
-	var firstFile string
-	for _, file := range files {
-		if file.IsDir() {
-			continue
+	var squashed []string
+	if regenerate {
+		// A plan diffed from the schemas only holds DDL, so the rows written by the pending migrations
+		// would be lost.
+		for _, m := range pending {
+			for _, stmt := range sqlparse.Split(m.Content) {
+				if !sqlparse.IsDataStatement(stmt.SQL) {
+					continue
+				}
+				if !discardDataChanges {
+					return fmt.Errorf("migration %s changes data at line %d, which --regenerate would drop: "+
+						"pass --discard-data-changes to squash anyway", m.Filename, stmt.Line)
+				}
+				slog.Warn("dropping data statement", "migration", m.Filename, "line", stmt.Line)
+			}
 		}
-		version, err := VersionFromFilename(file.Name())
+		squashed, err = regenerateMigrations(ctx, conf, applied, pending)
 		if err != nil {
 			return err
 		}
-		if version > currentVersion {
-			if firstFile == "" {
-				firstFile = file.Name()
-			}
-			pendingFiles = append(pendingFiles, file.Name())
+	} else {
+		// Combine all migrations into one file
+		var combinedMigration string
+		for _, m := range pending {
+			combinedMigration += m.Content + "\n"
+		}
+		if combinedMigration != "" {
+			squashed = []string{combinedMigration}
 		}
 	}
 
-	// Combine all migrations into one file
-	var combinedMigration string
-	for _, content := range pendingMigrations {
-		combinedMigration += content + "\n"
+	// The first squashed migration takes the first pending file, and the ones a regenerated plan is split
+	// into the versions after it, as diff names them.
+	firstVersion, err := strconv.Atoi(pending[0].Version)
+	if err != nil {
+		return fmt.Errorf("invalid version %s: %w", pending[0].Version, err)
 	}
-
-	// Write combined migration to first file
-	firstFilePath := filepath.Join(conf.GetMigrationDir(), firstFile)
-	if err := os.WriteFile(firstFilePath, []byte(combinedMigration), 0644); err != nil {
-		return err
+	filenames := make([]string, len(squashed))
+	for i := range squashed {
+		filenames[i] = pending[0].Filename
+		if i > 0 {
+			filenames[i] = fmt.Sprintf("%04d.sql", firstVersion+i)
+		}
 	}
 
-	// Delete other pending migration files
-	for _, file := range pendingFiles {
-		if file == firstFile {
+	// Delete the pending migration files that are not overwritten
+	for _, m := range pending {
+		if slices.Contains(filenames, m.Filename) {
 			continue
 		}
-		if err := os.Remove(filepath.Join(conf.GetMigrationDir(), file)); err != nil {
+		if err := os.Remove(filepath.Join(conf.GetMigrationDir(), m.Filename)); err != nil {
+			return err
+		}
+	}
+
+	for i, migration := range squashed {
+		if err := os.WriteFile(filepath.Join(conf.GetMigrationDir(), filenames[i]), []byte(migration), 0644); err != nil {
 			return err
 		}
 	}
 
-	println("✅ Squashed migrations")
+	if len(squashed) == 0 {
+		slog.Info("pending migrations cancel each other out, removed them")
+		return nil
+	}
+
+	slog.Info("squashed migrations", "migrations", len(squashed))
+
+	return nil
+}
+
+// regenerateMigrations replays the applied migrations into one temp database and all migrations into
+// another, then diffs the two to produce a minimal plan. The plan goes through the diff block of the env
+// like the one of diff, so it may be split into several migrations. The result is verified by applying
+// it on top of the applied migrations and comparing schema hashes. Nothing is returned if the pending
+// migrations leave the schema unchanged.
+func regenerateMigrations(ctx context.Context, conf *config.Config, applied, pending []engine.Migration) ([]string, error) {
+	tempDbFactory, err := engine.NewTempDbFactory(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	defer engine.CloseTempDbFactory(slog.Default(), tempDbFactory)
+
+	fromDb, err := tempDbFactory.Create(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("creating temp database: %w", err)
+	}
+	defer engine.CloseTempDb(ctx, slog.Default(), fromDb)
+	if err := engine.ReplayMigrations(ctx, fromDb, applied); err != nil {
+		return nil, err
+	}
 
+	toDb, err := tempDbFactory.Create(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("creating temp database: %w", err)
+	}
+	defer engine.CloseTempDb(ctx, slog.Default(), toDb)
+	if err := engine.ReplayMigrations(ctx, toDb, append(append([]engine.Migration{}, applied...), pending...)); err != nil {
+		return nil, err
+	}
+
+	schemaOpts := append(engine.SchemaFilterOpts(conf), fromDb.ExcludeMetadataOptions...)
+
+	diffConf := conf.GetDiffConfig()
+	plan, err := diff.Generate(ctx, fromDb.ConnPool, diff.DBSchemaSource(toDb.ConnPool), append(engine.PlanOpts(diffConf),
+		diff.WithGetSchemaOpts(schemaOpts...),
+		diff.WithTempDbFactory(tempDbFactory),
+	)...)
+	if err != nil {
+		return nil, err
+	}
+	plan = engine.AdaptPlan(diffConf, plan)
+	if len(plan.Statements) == 0 {
+		return nil, nil
+	}
+
+	migrations := engine.PlanToMigrations(diffConf, plan)
+	var squashed []engine.Migration
+	for _, migration := range migrations {
+		squashed = append(squashed, engine.Migration{Filename: pending[0].Filename, Content: migration})
+	}
+	if err := engine.ReplayMigrations(ctx, fromDb, squashed); err != nil {
+		return nil, fmt.Errorf("verifying squashed migration: %w", err)
+	}
+	if err := assertSameSchema(ctx, fromDb, toDb, schemaOpts); err != nil {
+		return nil, err
+	}
+	return migrations, nil
+}
+
+func assertSameSchema(ctx context.Context, a, b *tempdb.Database, opts []schema.GetSchemaOpt) error {
+	hashA, err := schema.GetSchemaHash(ctx, a.ConnPool, opts...)
+	if err != nil {
+		return err
+	}
+	hashB, err := schema.GetSchemaHash(ctx, b.ConnPool, opts...)
+	if err != nil {
+		return err
+	}
+	if hashA != hashB {
+		return errors.New("schema produced by the squashed migration does not match the pending migrations")
+	}
 	return nil
 }
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cortea-ai/pg-migrant/internal/engine"
+)
+
+func printStatementResults(results []engine.StatementResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  LINE\tDURATION\tROWS\tSTATUS\tSTATEMENT")
+	for _, r := range results {
+		duration, rows, status := r.Duration.Round(time.Microsecond).String(), fmt.Sprint(r.RowsAffected), "ok"
+		switch {
+		case r.Skipped:
+			duration, rows, status = "-", "-", "skipped"
+		case r.Err != nil:
+			rows, status = "-", "failed"
+		}
+		line := "-"
+		if r.Line > 0 {
+			line = fmt.Sprint(r.Line)
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", line, duration, rows, status, engine.FirstLine(r.SQL))
+		for _, lock := range r.Locks {
+			fmt.Fprintf(w, "  \t\t\t\t  lock: %s\n", lock)
+		}
+		if r.Err != nil {
+			fmt.Fprintf(w, "  \t\t\t\t  error: %v\n", r.Err)
+		}
+	}
+	w.Flush()
+}
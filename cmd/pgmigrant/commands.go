@@ -0,0 +1,670 @@
+package pgmigrant
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/cortea-ai/pg-migrant/cmd/cli"
+	"github.com/cortea-ai/pg-migrant/internal/config"
+	"github.com/cortea-ai/pg-migrant/internal/tracing"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const pgMigrant = "pg-migrant"
+
+// globalFlags holds the flags shared by the commands of a root command, so that several root commands
+// can be built without sharing their flags.
+type globalFlags struct {
+	configPath string
+	env        string
+	vars       config.Vars
+	logLevel   string
+	logFormat  string
+}
+
+func newGlobalFlags() *globalFlags {
+	return &globalFlags{vars: make(config.Vars), logLevel: "info", logFormat: logFormatText}
+}
+
+// NewRootCmd returns the pg-migrant command with all of its subcommands. A binary registering Go
+// migrations with migrant.Register builds it to offer the same commands as pg-migrant.
+func NewRootCmd(version string) *cobra.Command {
+	flags := newGlobalFlags()
+	rootCmd := &cobra.Command{
+		Use:          pgMigrant,
+		Short:        "A cli utility for db migrations",
+		SilenceUsage: true,
+		// Errors are printed by Main so that sensitive values can be redacted.
+		SilenceErrors: true,
+		Version:       version,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			logger, err := newLogger(os.Stderr, flags.logLevel, flags.logFormat, version)
+			if err != nil {
+				return err
+			}
+			slog.SetDefault(logger)
+			return nil
+		},
+	}
+	rootCmd.SetOut(os.Stdout)
+	rootCmd.AddCommand(dbLastMigrationCmd(flags))
+	rootCmd.AddCommand(repoLastMigrationCmd(flags))
+	rootCmd.AddCommand(diffCmd(flags))
+	rootCmd.AddCommand(applyCmd(flags))
+	rootCmd.AddCommand(pendingMigrationsCmd(flags))
+	rootCmd.AddCommand(checkCmd(flags))
+	rootCmd.AddCommand(squashCmd(flags))
+	rootCmd.AddCommand(cleanCmd(flags))
+	rootCmd.AddCommand(baselineCmd(flags))
+	rootCmd.AddCommand(markAppliedCmd(flags))
+	rootCmd.AddCommand(unmarkCmd(flags))
+	rootCmd.AddCommand(repairCmd(flags))
+	rootCmd.AddCommand(configCmd(flags))
+	rootCmd.AddCommand(initCmd())
+	rootCmd.AddCommand(dumpSchemaCmd(flags))
+	rootCmd.AddCommand(rehearseCmd(flags))
+	rootCmd.AddCommand(metricsCmd(flags))
+	rootCmd.AddCommand(versionCmd(version))
+	return rootCmd
+}
+
+// Main runs the pg-migrant command with the arguments of the process and exits with a non-zero status
+// on error.
+func Main(version string) {
+	rootCmd := NewRootCmd(version)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		// On first signal seen, cancel the context. On the second signal, force stop immediately.
+		stop := make(chan os.Signal, 2)
+		defer close(stop)
+		signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+		defer signal.Stop(stop)
+		<-stop   // wait for first interrupt
+		cancel() // cancel context to gracefully stop
+		slog.Warn("interrupt received, wait for exit or ^C to terminate")
+		// Wait for the context to be canceled. Issuing a second interrupt will cause the process to force stop.
+		<-stop // will not block if no signal received due to main routine exiting
+		os.Exit(1)
+	}()
+
+	shutdown, err := tracing.Setup(ctx, version)
+	if err != nil {
+		slog.Warn("setting up tracing failed", "error", err)
+		shutdown = func(context.Context) error { return nil }
+	}
+	ctx = tracing.ContextFromEnv(ctx)
+	name := pgMigrant
+	if cmd, _, err := rootCmd.Find(os.Args[1:]); err == nil {
+		name = cmd.CommandPath()
+	}
+	ctx, span := tracing.Start(ctx, name)
+	err = rootCmd.ExecuteContext(ctx)
+	tracing.End(span, err)
+	// Spans are flushed even when the run was interrupted.
+	flushCtx, flushCancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	if err := shutdown(flushCtx); err != nil {
+		slog.Warn("flushing traces failed", "error", err)
+	}
+	flushCancel()
+	if err != nil {
+		slog.Error("pg-migrant failed", "error", config.Redact(err.Error()))
+		os.Exit(1)
+	}
+}
+
+func (f *globalFlags) add(set *pflag.FlagSet) {
+	set.StringVar(&f.env, "env", "", "set which env to use from the config file, defaults to default_env or the only env")
+	set.Var(&f.vars, "var", "input variables")
+	set.StringVarP(&f.configPath, "config", "c", "./"+pgMigrant+".hcl", "Path to the configuration file")
+	set.StringVar(&f.logLevel, "log-level", f.logLevel, "Minimum level of the logs: debug, info, warn or error")
+	set.StringVar(&f.logFormat, "log-format", f.logFormat, "Format of the logs: text or json")
+}
+
+// getConfig loads the config file and selects the env of the flags. Every log line then carries the env.
+func (f *globalFlags) getConfig(ctx context.Context) (*config.Config, error) {
+	_, span := tracing.Start(ctx, "config.load", tracing.ConfigKey.String(f.configPath))
+	conf, err := config.GetConfig(f.configPath, f.env, f.vars)
+	if err == nil {
+		span.SetAttributes(tracing.EnvKey.String(conf.SelectedEnv.Name))
+	}
+	tracing.End(span, err)
+	if err != nil {
+		return nil, err
+	}
+	setLogEnv(conf.SelectedEnv.Name)
+	return conf, nil
+}
+
+func dbLastMigrationCmd(flags *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db-last-migration",
+		Short: "Get the last migration version of the db",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := flags.getConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return cli.DBLastMigration(cmd.Context(), conf)
+		},
+	}
+	flags.add(cmd.PersistentFlags())
+	return cmd
+}
+
+func repoLastMigrationCmd(flags *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "repo-last-migration",
+		Short: "Get the last migration version commited to the repo. Requires GITHUB_TOKEN.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := flags.getConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+			token, ok := os.LookupEnv("GITHUB_TOKEN")
+			if !ok {
+				return fmt.Errorf("GITHUB_TOKEN is not set")
+			}
+			return cli.RepoLastMigration(cmd.Context(), conf, token)
+		},
+	}
+	flags.add(cmd.PersistentFlags())
+	return cmd
+}
+
+func diffCmd(flags *globalFlags) *cobra.Command {
+	var (
+		migrate = "migrate"
+	)
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Diff the current schema against the db",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := flags.getConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+			migrate, err := cmd.Flags().GetBool(migrate)
+			if err != nil {
+				return err
+			}
+			diffConf, err := diffConfigFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			conf.OverrideDiffConfig(diffConf)
+			return cli.Diff(cmd.Context(), conf, migrate)
+		},
+	}
+	flags.add(cmd.PersistentFlags())
+	cmd.Flags().Bool(migrate, false, "Run diffed migrations on the fly")
+	addDiffConfigFlags(cmd.Flags())
+	return cmd
+}
+
+// Flags overriding the options of the diff block of the env.
+const (
+	dataPackNewTablesFlag   = "data-pack-new-tables"
+	respectColumnOrderFlag  = "respect-column-order"
+	notValidForeignKeysFlag = "not-valid-foreign-keys"
+	concurrentIndexesFlag   = "concurrent-indexes"
+	ignoreHazardsFlag       = "ignore-hazards"
+	validatePlanFlag        = "validate-plan"
+)
+
+func addDiffConfigFlags(set *pflag.FlagSet) {
+	set.Bool(dataPackNewTablesFlag, true, "Order the columns of new tables to minimize padding (diff.data_pack_new_tables)")
+	set.Bool(respectColumnOrderFlag, false, "Recreate tables whose column order changed (diff.respect_column_order)")
+	set.Bool(notValidForeignKeysFlag, false, "Add foreign keys on existing tables as NOT VALID, then validate them (diff.not_valid_foreign_keys)")
+	set.Bool(concurrentIndexesFlag, true, "Build and drop indexes concurrently, in migrations of their own running outside of a transaction (diff.concurrent_indexes)")
+	set.StringSlice(ignoreHazardsFlag, nil, "Hazard types to leave out of generated migrations (diff.ignore_hazards)")
+	set.Bool(validatePlanFlag, true, "Validate generated plans against a temp database (diff.validate_plan)")
+}
+
+// diffConfigFlags returns the diff options set on the command line.
+func diffConfigFlags(set *pflag.FlagSet) (config.DiffConfig, error) {
+	var diffConf config.DiffConfig
+	for name, dst := range map[string]**bool{
+		dataPackNewTablesFlag:   &diffConf.DataPackNewTables,
+		respectColumnOrderFlag:  &diffConf.RespectColumnOrder,
+		notValidForeignKeysFlag: &diffConf.NotValidForeignKeys,
+		concurrentIndexesFlag:   &diffConf.ConcurrentIndexes,
+		validatePlanFlag:        &diffConf.ValidatePlan,
+	} {
+		if !set.Changed(name) {
+			continue
+		}
+		value, err := set.GetBool(name)
+		if err != nil {
+			return config.DiffConfig{}, err
+		}
+		*dst = &value
+	}
+	if set.Changed(ignoreHazardsFlag) {
+		hazards, err := set.GetStringSlice(ignoreHazardsFlag)
+		if err != nil {
+			return config.DiffConfig{}, err
+		}
+		diffConf.IgnoreHazards = hazards
+	}
+	return diffConf, nil
+}
+
+func applyCmd(flags *globalFlags) *cobra.Command {
+	var (
+		autoApprove = "auto-approve"
+		dryRun      = "dry-run"
+	)
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Apply pending migrations",
+		// Rejects `--dry-run execute`, which would otherwise be read as --dry-run and an argument.
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := flags.getConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+			autoApprove, err := cmd.Flags().GetBool(autoApprove)
+			if err != nil {
+				return err
+			}
+			dryRun, err := cmd.Flags().GetString(dryRun)
+			if err != nil {
+				return err
+			}
+			// --dry-run used to be a boolean flag: keep accepting its values.
+			if b, err := strconv.ParseBool(dryRun); err == nil {
+				dryRun = ""
+				if b {
+					dryRun = cli.DryRunPrint
+				}
+			}
+			if err := overrideMetricsConfig(cmd.Flags(), conf); err != nil {
+				return err
+			}
+			return cli.Apply(cmd.Context(), conf, autoApprove, dryRun)
+		},
+	}
+	flags.add(cmd.PersistentFlags())
+	cmd.Flags().Bool(autoApprove, false, "Automatically approve migrations")
+	cmd.Flags().String(dryRun, "", "Print the pending migrations (print or true), or execute them in a transaction that is rolled back (execute)")
+	cmd.Flags().Lookup(dryRun).NoOptDefVal = cli.DryRunPrint
+	addMetricsConfigFlags(cmd.Flags())
+	return cmd
+}
+
+// Flags overriding the options of the metrics block of the env.
+const (
+	metricsTextfileFlag = "metrics-textfile"
+	metricsPushURLFlag  = "metrics-push-url"
+	metricsJobFlag      = "metrics-job"
+)
+
+func addMetricsConfigFlags(set *pflag.FlagSet) {
+	set.String(metricsTextfileFlag, "", "Write metrics to this file for the node exporter textfile collector (metrics.textfile)")
+	set.String(metricsPushURLFlag, "", "Push metrics to the Pushgateway at this URL (metrics.push_url)")
+	set.String(metricsJobFlag, "", "Job to push metrics under, defaults to "+config.DefaultMetricsJob+" (metrics.job)")
+}
+
+// overrideMetricsConfig replaces the metrics options of the env with the ones set on the command line.
+func overrideMetricsConfig(set *pflag.FlagSet, conf *config.Config) error {
+	var metricsConf config.MetricsConfig
+	for name, dst := range map[string]*string{
+		metricsTextfileFlag: &metricsConf.Textfile,
+		metricsPushURLFlag:  &metricsConf.PushURL,
+		metricsJobFlag:      &metricsConf.Job,
+	} {
+		value, err := set.GetString(name)
+		if err != nil {
+			return err
+		}
+		*dst = value
+	}
+	conf.OverrideMetricsConfig(metricsConf)
+	return nil
+}
+
+func metricsCmd(flags *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "metrics",
+		Short: "Export the pending and applied migrations of the db as Prometheus gauges",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := flags.getConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if err := overrideMetricsConfig(cmd.Flags(), conf); err != nil {
+				return err
+			}
+			return cli.Metrics(cmd.Context(), conf)
+		},
+	}
+	flags.add(cmd.PersistentFlags())
+	addMetricsConfigFlags(cmd.Flags())
+	return cmd
+}
+
+func pendingMigrationsCmd(flags *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pending-migrations",
+		Short: "Print the version for each pending migration",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := flags.getConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return cli.PendingMigrations(cmd.Context(), conf)
+		},
+	}
+	flags.add(cmd.PersistentFlags())
+	return cmd
+}
+
+func checkCmd(flags *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check need for rebasing and no gaps in version numbering. Requires GITHUB_TOKEN.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := flags.getConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+			token, ok := os.LookupEnv("GITHUB_TOKEN")
+			if !ok {
+				return fmt.Errorf("GITHUB_TOKEN is not set")
+			}
+			return cli.Check(cmd.Context(), conf, token)
+		},
+	}
+	flags.add(cmd.PersistentFlags())
+	return cmd
+}
+
+func squashCmd(flags *globalFlags) *cobra.Command {
+	var (
+		regenerate         = "regenerate"
+		discardDataChanges = "discard-data-changes"
+	)
+	cmd := &cobra.Command{
+		Use:   "squash",
+		Short: "Squash pending migrations into a single migration. Requires GITHUB_TOKEN.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := flags.getConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+			token, ok := os.LookupEnv("GITHUB_TOKEN")
+			if !ok {
+				return fmt.Errorf("GITHUB_TOKEN is not set")
+			}
+			regenerate, err := cmd.Flags().GetBool(regenerate)
+			if err != nil {
+				return err
+			}
+			discardDataChanges, err := cmd.Flags().GetBool(discardDataChanges)
+			if err != nil {
+				return err
+			}
+			return cli.Squash(cmd.Context(), conf, token, regenerate, discardDataChanges)
+		},
+	}
+	flags.add(cmd.PersistentFlags())
+	cmd.Flags().Bool(regenerate, false, "Replace pending migrations with a plan diffed from a temp database")
+	cmd.Flags().Bool(discardDataChanges, false, "With --regenerate, drop the statements of pending migrations that write rows")
+	return cmd
+}
+
+func cleanCmd(flags *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clean",
+		Short: "Clean existing database schema. Requires `allow_db_clean=true`.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := flags.getConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return cli.Clean(cmd.Context(), conf)
+		},
+	}
+	flags.add(cmd.PersistentFlags())
+	return cmd
+}
+
+func baselineCmd(flags *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "baseline <version>",
+		Short: "Collapse all migrations up to version into a single baseline migration",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := flags.getConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return cli.Baseline(cmd.Context(), conf, args[0])
+		},
+	}
+	flags.add(cmd.PersistentFlags())
+	return cmd
+}
+
+func markAppliedCmd(flags *globalFlags) *cobra.Command {
+	var (
+		note  = "note"
+		force = "force"
+	)
+	cmd := &cobra.Command{
+		Use:   "mark-applied <version>",
+		Short: "Record a migration as applied without running it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := flags.getConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+			note, err := cmd.Flags().GetString(note)
+			if err != nil {
+				return err
+			}
+			force, err := cmd.Flags().GetBool(force)
+			if err != nil {
+				return err
+			}
+			return cli.MarkApplied(cmd.Context(), conf, args[0], note, force)
+		},
+	}
+	flags.add(cmd.PersistentFlags())
+	cmd.Flags().String(note, "", "Audit note explaining the change")
+	cmd.Flags().Bool(force, false, "Mark the migration even though earlier ones are pending, which apply then skips")
+	return cmd
+}
+
+func unmarkCmd(flags *globalFlags) *cobra.Command {
+	var (
+		note = "note"
+	)
+	cmd := &cobra.Command{
+		Use:   "unmark <version>",
+		Short: "Remove a migration from the migration history without reverting it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := flags.getConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+			note, err := cmd.Flags().GetString(note)
+			if err != nil {
+				return err
+			}
+			return cli.Unmark(cmd.Context(), conf, args[0], note)
+		},
+	}
+	flags.add(cmd.PersistentFlags())
+	cmd.Flags().String(note, "", "Audit note explaining the change")
+	return cmd
+}
+
+func repairCmd(flags *globalFlags) *cobra.Command {
+	var (
+		note = "note"
+	)
+	cmd := &cobra.Command{
+		Use:   "repair",
+		Short: "Recompute migration checksums and remove failed entries from the migration history",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := flags.getConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+			note, err := cmd.Flags().GetString(note)
+			if err != nil {
+				return err
+			}
+			return cli.Repair(cmd.Context(), conf, note)
+		},
+	}
+	flags.add(cmd.PersistentFlags())
+	cmd.Flags().String(note, "", "Audit note explaining the change")
+	return cmd
+}
+
+func configCmd(flags *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration file",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show",
+		Short: "Print the resolved settings of an env and where each one was set",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := flags.getConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return cli.ConfigShow(conf)
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "validate",
+		Short: "Check the configuration file, for every env unless --env is set",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := config.LoadConfig(flags.configPath, flags.vars)
+			if err != nil {
+				return err
+			}
+			if flags.env != "" {
+				if conf, err = conf.Select(flags.env); err != nil {
+					return err
+				}
+			}
+			return cli.ConfigValidate(conf)
+		},
+	})
+	flags.add(cmd.PersistentFlags())
+	return cmd
+}
+
+func initCmd() *cobra.Command {
+	var (
+		fromDB = "from-db"
+	)
+	cmd := &cobra.Command{
+		Use:   "init [dir]",
+		Short: "Scaffold a pg-migrant project, optionally from an existing database",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := "."
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			fromDB, err := cmd.Flags().GetString(fromDB)
+			if err != nil {
+				return err
+			}
+			return cli.Init(cmd.Context(), dir, fromDB)
+		},
+	}
+	cmd.Flags().String(fromDB, "", "Connection URL of a database to generate the schema files and a baseline migration from")
+	return cmd
+}
+
+func dumpSchemaCmd(flags *globalFlags) *cobra.Command {
+	var (
+		dir    = "dir"
+		layout = "layout"
+		prune  = "prune"
+	)
+	cmd := &cobra.Command{
+		Use:   "dump-schema",
+		Short: "Write the schema of the database as declarative schema files",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := flags.getConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+			dir, err := cmd.Flags().GetString(dir)
+			if err != nil {
+				return err
+			}
+			layout, err := cmd.Flags().GetString(layout)
+			if err != nil {
+				return err
+			}
+			prune, err := cmd.Flags().GetBool(prune)
+			if err != nil {
+				return err
+			}
+			return cli.DumpSchema(cmd.Context(), conf, dir, layout, prune)
+		},
+	}
+	flags.add(cmd.PersistentFlags())
+	cmd.Flags().String(dir, "schema", "Directory to write the schema files to")
+	cmd.Flags().String(layout, cli.LayoutSchema, "Write one file per schema (schema) or one file per table (table)")
+	cmd.Flags().Bool(prune, false, "Remove .sql files in the directory that are no longer part of the schema")
+	return cmd
+}
+
+func rehearseCmd(flags *globalFlags) *cobra.Command {
+	var (
+		sampleRows = "sample-rows"
+	)
+	cmd := &cobra.Command{
+		Use:   "rehearse",
+		Short: "Apply pending migrations to a temp copy of the database and report timings and locks",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := flags.getConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+			rows, err := cmd.Flags().GetInt(sampleRows)
+			if err != nil {
+				return err
+			}
+			if rows < 0 {
+				return fmt.Errorf("--%s must not be negative", sampleRows)
+			}
+			return cli.Rehearse(cmd.Context(), conf, rows)
+		},
+	}
+	flags.add(cmd.PersistentFlags())
+	cmd.Flags().Int(sampleRows, 0, "Copy up to this many rows of each table into the temp database")
+	return cmd
+}
+
+func versionCmd(version string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the version number of pg-migrant",
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Println("pg-migrant", version)
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,89 @@
+package pgmigrant
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+
+	"github.com/cortea-ai/pg-migrant/internal/config"
+)
+
+// Log formats of --log-format.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// newLogger returns a logger writing records of at least level to w, in format. Sensitive values of the
+// config are redacted from every message and attribute. Every line carries a version: the version of the
+// migration it is about, or else the version of pg-migrant.
+func newLogger(w io.Writer, level, format, version string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", level)
+	}
+	opts := &slog.HandlerOptions{Level: l, ReplaceAttr: redactAttr}
+	var h slog.Handler
+	switch format {
+	case logFormatText:
+		h = slog.NewTextHandler(w, opts)
+	case logFormatJSON:
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q, expected %q or %q", format, logFormatText, logFormatJSON)
+	}
+	return slog.New(&defaultsHandler{Handler: h, defaults: []slog.Attr{slog.String("version", version)}}), nil
+}
+
+// setLogEnv makes every line of the default logger carry env, once the config selected it.
+func setLogEnv(env string) {
+	slog.SetDefault(slog.Default().With("env", env))
+}
+
+// defaultsHandler adds its defaults to the records that do not set these attributes themselves, neither
+// in the record nor in the attributes of their logger.
+type defaultsHandler struct {
+	slog.Handler
+	defaults []slog.Attr
+}
+
+func (h *defaultsHandler) Handle(ctx context.Context, r slog.Record) error {
+	if len(h.defaults) > 0 {
+		set := make(map[string]bool, r.NumAttrs())
+		r.Attrs(func(a slog.Attr) bool {
+			set[a.Key] = true
+			return true
+		})
+		r = r.Clone()
+		for _, a := range h.defaults {
+			if !set[a.Key] {
+				r.AddAttrs(a)
+			}
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *defaultsHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	defaults := slices.DeleteFunc(slices.Clone(h.defaults), func(d slog.Attr) bool {
+		return slices.ContainsFunc(attrs, func(a slog.Attr) bool { return a.Key == d.Key })
+	})
+	return &defaultsHandler{Handler: h.Handler.WithAttrs(attrs), defaults: defaults}
+}
+
+// WithGroup adds the defaults before the group, as the attributes of the group cannot override them.
+func (h *defaultsHandler) WithGroup(name string) slog.Handler {
+	return &defaultsHandler{Handler: h.Handler.WithAttrs(h.defaults).WithGroup(name)}
+}
+
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	switch v := a.Value.Any().(type) {
+	case string:
+		a.Value = slog.StringValue(config.Redact(v))
+	case error:
+		a.Value = slog.StringValue(config.Redact(v.Error()))
+	}
+	return a
+}
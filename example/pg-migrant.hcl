@@ -8,14 +8,19 @@ variable "postgres_host" {
   default = "localhost"
 }
 variable "postgres_port" {
-  default = "5432"
+  type = number
+  default = 5432
+  validation {
+    condition = var.postgres_port > 0 && var.postgres_port < 65536
+    error_message = "postgres_port must be a valid TCP port."
+  }
 }
 variable "postgres_dbname" {
   default = "cortea"
 }
 
 locals {
-  // Order matters!
+  // Files, directories or glob patterns, sorted by the objects they reference.
   schema_files = [
     "example/schema/extensions.sql",
     "example/schema/contacts.sql",
@@ -28,22 +33,28 @@ locals {
   }
 }
 
-env "dev" {
+default_env = "dev"
+
+// Settings shared by every env. An env can override any of them.
+defaults {
   schema_files = local.schema_files
   migration_dir = "example/migrations"
-  db_url = "postgres://${var.postgres_user}:${var.postgres_password}@${var.postgres_host}:${var.postgres_port}/${var.postgres_dbname}?search_path=public&sslmode=disable"
   github_config = local.github_config
   exclude_schemas = ["custom"]
 }
 
+env "dev" {
+  db_url = "postgres://${var.postgres_user}:${var.postgres_password}@${var.postgres_host}:${var.postgres_port}/${var.postgres_dbname}?search_path=public&sslmode=disable"
+}
+
 variable "postgres_password" {
+  type = string
+  description = "Password of the postgres user, read from POSTGRES_PASSWORD by default"
+  sensitive = true
   default = getenv("POSTGRES_PASSWORD")
 }
 
+// prod inherits every setting of dev, including the ones dev gets from defaults.
 env "prod" {
-  schema_files = local.schema_files
-  migration_dir = "example/migrations"
-  db_url = "postgres://${var.postgres_user}:${var.postgres_password}@${var.postgres_host}:${var.postgres_port}/${var.postgres_dbname}?search_path=public&sslmode=disable"
-  github_config = local.github_config
-  exclude_schemas = ["custom"]
+  extends = env.dev
 }
@@ -5,17 +5,25 @@ import (
 	"io/fs"
 	"os"
 
+	"github.com/cortea-ai/pg-migrant/internal/db"
+	"github.com/cortea-ai/pg-migrant/internal/fsutil"
 	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/hashicorp/hcl/v2/hclparse"
-	"github.com/hashicorp/hcl/v2/hclsimple"
 	"github.com/jackc/pgx/v4"
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/function"
 )
 
 type Variable struct {
-	Name    string `hcl:"name,label"`
-	Default string `hcl:"default,optional"`
+	Name        string         `hcl:"name,label"`
+	Type        *hcl.Attribute `hcl:"type,optional"`
+	Default     cty.Value      `hcl:"default,optional"`
+	Description string         `hcl:"description,optional"`
+	Sensitive   bool           `hcl:"sensitive,optional"`
+	Validations []Validation   `hcl:"validation,block"`
+	// Value is the resolved value of the variable.
+	Value cty.Value
 }
 
 type Locals struct {
@@ -29,44 +37,86 @@ type GitHubConfig struct {
 }
 
 type Env struct {
-	Name           string       `hcl:"name,label"`
-	DBUrl          string       `hcl:"db_url"`
-	MigrationDir   string       `hcl:"migration_dir,optional" default:"./migrations"`
-	SchemaFiles    []string     `hcl:"schema_files"`
-	GitHubConfig   GitHubConfig `hcl:"github_config,optional"`
-	ExcludeSchemas []string     `hcl:"exclude_schemas,optional"`
-	AllowDBClean   bool         `hcl:"allow_db_clean,optional"`
+	Name           string         `hcl:"name,label"`
+	Extends        hcl.Expression `hcl:"extends,optional"`
+	DBUrl          string         `hcl:"db_url,optional"`
+	MigrationDir   string         `hcl:"migration_dir,optional" default:"./migrations"`
+	RepeatableDir  string         `hcl:"repeatable_dir,optional"`
+	SchemaFiles    []string       `hcl:"schema_files,optional"`
+	GitHubConfig   GitHubConfig   `hcl:"github_config,optional"`
+	IncludeSchemas []string       `hcl:"include_schemas,optional"`
+	ExcludeSchemas []string       `hcl:"exclude_schemas,optional"`
+	AllowDBClean   bool           `hcl:"allow_db_clean,optional"`
+	Diff           *DiffConfig    `hcl:"diff,block"`
+	Metrics        *MetricsConfig `hcl:"metrics,block"`
+	Notify         *NotifyConfig  `hcl:"notify,block"`
+	Hooks          *HooksConfig   `hcl:"hooks,block"`
+	// Origins maps each setting to the block it was resolved from, e.g. "defaults" or "env.dev".
+	Origins map[string]string
 }
 
 type Config struct {
+	DefaultEnv  string     `hcl:"default_env,optional"`
 	Variables   []Variable `hcl:"variable,block"`
 	Locals      *Locals    `hcl:"locals,block"`
 	Envs        []Env      `hcl:"env,block"`
 	SelectedEnv Env
+	// FS is the file system the migrations and schema files are read from, the operating system's if nil.
+	FS fs.FS
 }
 
+// GetConfig loads the config file and selects env. If env is empty, the default env is selected.
 func GetConfig(filePath string, env string, vars Vars) (*Config, error) {
-	var config Config
+	return GetConfigFS(fsutil.OS, filePath, env, vars)
+}
+
+// GetConfigFS is like GetConfig, but reads the config file, and later the migrations and schema files
+// it references, from fsys.
+func GetConfigFS(fsys fs.FS, filePath string, env string, vars Vars) (*Config, error) {
+	config, err := LoadConfigFS(fsys, filePath, vars)
+	if err != nil {
+		return nil, err
+	}
+	return config.Select(env)
+}
+
+// LoadConfig loads the config file with all of its envs and no env selected.
+func LoadConfig(filePath string, vars Vars) (*Config, error) {
+	return LoadConfigFS(fsutil.OS, filePath, vars)
+}
+
+// LoadConfigFS is like LoadConfig, but reads the config file, and later the migrations and schema files
+// it references, from fsys.
+func LoadConfigFS(fsys fs.FS, filePath string, vars Vars) (*Config, error) {
+	config := Config{FS: fsys}
 
+	src, err := fs.ReadFile(fsys, fsutil.Clean(filePath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
 	parser := hclparse.NewParser()
-	hclFile, diags := parser.ParseHCLFile(filePath)
+	hclFile, diags := parser.ParseHCL(src, filePath)
 	if diags.HasErrors() {
 		return nil, fmt.Errorf("failed to parse HCL file: %w", diags)
 	}
 	variables := make(map[string]cty.Value)
+	functions := secretFuncs()
+	functions["getenv"] = getEnvFunc
 	evalCtx := &hcl.EvalContext{
-		Functions: map[string]function.Function{
-			"getenv": getEnvFunc,
-		},
+		Functions: functions,
 		Variables: map[string]cty.Value{
 			"var": cty.ObjectVal(variables),
 		},
 	}
 	schema := &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{
+			{Name: "default_env"},
+		},
 		Blocks: []hcl.BlockHeaderSchema{
 			{Type: "variable", LabelNames: []string{"name"}},
 			{Type: "locals", LabelNames: []string{}},
-			{Type: "env", LabelNames: []string{"name"}},
+			{Type: envBlock, LabelNames: []string{"name"}},
+			{Type: defaultsBlock},
 		},
 	}
 	content, diags := hclFile.Body.Content(schema)
@@ -74,28 +124,27 @@ func GetConfig(filePath string, env string, vars Vars) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse body content: %w", diags)
 	}
 
-	// Collect variables
+	// Collect variables. A variable declared more than once takes its last declaration.
+	declared := make(map[string]int)
 	for _, block := range content.Blocks.OfType("variable") {
-		varName := block.Labels[0]
-		attrs, diags := block.Body.JustAttributes()
-		if diags.HasErrors() {
-			return nil, fmt.Errorf("failed to parse attributes of variable '%s': %w", varName, diags)
-		}
-		if val, ok := vars[varName]; ok {
-			variables[varName] = cty.StringVal(val) // only strings are supported for now
-			continue
+		v, err := decodeVariable(block, evalCtx, vars)
+		if err != nil {
+			return nil, err
 		}
-		defaultAttr, ok := attrs["default"]
-		if !ok {
-			continue
-		}
-		defaultVal, diags := defaultAttr.Expr.Value(evalCtx)
-		if diags.HasErrors() {
-			return nil, fmt.Errorf("failed to evaluate default value of '%s': %w", varName, diags)
+		if i, ok := declared[v.Name]; ok {
+			config.Variables[i] = v
+		} else {
+			declared[v.Name] = len(config.Variables)
+			config.Variables = append(config.Variables, v)
 		}
-		variables[varName] = defaultVal
+		variables[v.Name] = v.Value
 	}
 	evalCtx.Variables["var"] = cty.ObjectVal(variables)
+	for _, v := range config.Variables {
+		if err := v.validate(evalCtx); err != nil {
+			return nil, err
+		}
+	}
 
 	// Collect locals
 	if len(content.Blocks.OfType("locals")) > 0 {
@@ -115,23 +164,44 @@ func GetConfig(filePath string, env string, vars Vars) (*Config, error) {
 		evalCtx.Variables["local"] = cty.ObjectVal(localVals)
 	}
 
-	err := hclsimple.DecodeFile(filePath, evalCtx, &config)
+	if attr, ok := content.Attributes["default_env"]; ok {
+		if diags := gohcl.DecodeExpression(attr.Expr, evalCtx, &config.DefaultEnv); diags.HasErrors() {
+			return nil, fmt.Errorf("failed to evaluate default_env: %w", diags)
+		}
+	}
+
+	envs, err := resolveEnvs(content, evalCtx)
 	if err != nil {
 		return nil, err
 	}
+	config.Envs = envs
 
-	if env != "" {
-		for _, e := range config.Envs {
-			if e.Name == env {
-				return &Config{
-					Variables:   config.Variables,
-					Envs:        []Env{e},
-					SelectedEnv: e,
-				}, nil
-			}
+	return &config, nil
+}
+
+// Select returns the config restricted to env. If env is empty, default_env is selected, or the only
+// env of the file if there is a single one.
+func (conf *Config) Select(env string) (*Config, error) {
+	if env == "" {
+		env = conf.DefaultEnv
+	}
+	if env == "" {
+		if len(conf.Envs) != 1 {
+			return nil, fmt.Errorf("no environment selected: use --env or set default_env in the config")
+		}
+		env = conf.Envs[0].Name
+	}
+	for _, e := range conf.Envs {
+		if e.Name == env {
+			return &Config{
+				DefaultEnv:  conf.DefaultEnv,
+				Variables:   conf.Variables,
+				Envs:        []Env{e},
+				SelectedEnv: e,
+				FS:          conf.FS,
+			}, nil
 		}
 	}
-
 	return nil, fmt.Errorf("environment %q not found in config", env)
 }
 
@@ -142,20 +212,35 @@ func (conf *Config) GetDBUrl() string {
 func (conf *Config) GetDBConfig() (*pgx.ConnConfig, error) {
 	connConfig, err := pgx.ParseConfig(conf.SelectedEnv.DBUrl)
 	if err != nil {
-		return nil, err
+		return nil, db.SanitizeParseConfigError(err)
 	}
 	return connConfig, nil
 }
 
+// GetFS returns the file system the migrations and schema files are read from. Their paths, as written
+// in the config, are turned into paths of it with fsutil.Clean.
+func (conf *Config) GetFS() fs.FS {
+	if conf.FS == nil {
+		return fsutil.OS
+	}
+	return conf.FS
+}
+
 func (conf *Config) GetMigrationDir() string {
 	return conf.SelectedEnv.MigrationDir
 }
 
+// GetRepeatableDir returns the directory of the repeatable migrations, re-run whenever they change. It
+// is empty if the env has none.
+func (conf *Config) GetRepeatableDir() string {
+	return conf.SelectedEnv.RepeatableDir
+}
+
 func (conf *Config) GetMigrationFiles() ([]fs.DirEntry, error) {
 	if conf.GetMigrationDir() == "" {
 		return nil, nil
 	}
-	files, err := os.ReadDir(conf.GetMigrationDir())
+	files, err := fs.ReadDir(conf.GetFS(), fsutil.Clean(conf.GetMigrationDir()))
 	if err != nil {
 		return nil, fmt.Errorf("reading migration directory: %w", err)
 	}
@@ -170,6 +255,11 @@ func (conf *Config) GetGitHubConfig() GitHubConfig {
 	return conf.SelectedEnv.GitHubConfig
 }
 
+// GetIncludeSchemas returns the schemas managed by pg-migrant. If empty, every schema not excluded is.
+func (conf *Config) GetIncludeSchemas() []string {
+	return conf.SelectedEnv.IncludeSchemas
+}
+
 func (conf *Config) GetExcludeSchemas() []string {
 	return conf.SelectedEnv.ExcludeSchemas
 }
@@ -178,6 +268,48 @@ func (conf *Config) GetAllowDBClean() bool {
 	return conf.SelectedEnv.AllowDBClean
 }
 
+func (conf *Config) GetDiffConfig() DiffConfig {
+	if conf.SelectedEnv.Diff == nil {
+		return DiffConfig{}
+	}
+	return *conf.SelectedEnv.Diff
+}
+
+func (conf *Config) GetMetricsConfig() MetricsConfig {
+	if conf.SelectedEnv.Metrics == nil {
+		return MetricsConfig{}
+	}
+	return *conf.SelectedEnv.Metrics
+}
+
+func (conf *Config) GetNotifyConfig() NotifyConfig {
+	if conf.SelectedEnv.Notify == nil {
+		return NotifyConfig{}
+	}
+	return *conf.SelectedEnv.Notify
+}
+
+func (conf *Config) GetHooksConfig() HooksConfig {
+	if conf.SelectedEnv.Hooks == nil {
+		return HooksConfig{}
+	}
+	return *conf.SelectedEnv.Hooks
+}
+
+// OverrideMetricsConfig replaces the metrics options of the selected env with the ones set in o, e.g.
+// from command line flags.
+func (conf *Config) OverrideMetricsConfig(o MetricsConfig) {
+	m := conf.GetMetricsConfig().Override(o)
+	conf.SelectedEnv.Metrics = &m
+}
+
+// OverrideDiffConfig replaces the diff options of the selected env with the ones set in o, e.g. from
+// command line flags.
+func (conf *Config) OverrideDiffConfig(o DiffConfig) {
+	d := conf.GetDiffConfig().Override(o)
+	conf.SelectedEnv.Diff = &d
+}
+
 var getEnvFunc = function.New(&function.Spec{
 	Params: []function.Parameter{
 		{
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const testConfig = `
+default_env = "dev"
+
+env "dev" {
+  db_url        = "postgres://localhost:5432/dev"
+  migration_dir = "./db/migrations"
+  schema_files  = ["./db/schema/"]
+}
+
+env "prod" {
+  db_url       = "postgres://db.internal:5432/prod"
+  schema_files = ["db/schema/*.sql"]
+}
+`
+
+func TestGetConfigFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config/pgmigrant.hcl":   {Data: []byte(testConfig)},
+		"single/pgmigrant.hcl":   {Data: []byte("env \"only\" {\n  db_url = \"postgres://localhost/only\"\n  schema_files = [\"schema.sql\"]\n}\n")},
+		"db/migrations/0001.sql": {Data: []byte("CREATE TABLE users (id int);")},
+		"db/schema/users.sql":    {Data: []byte("CREATE TABLE users (id int);")},
+	}
+	tests := []struct {
+		name         string
+		path         string
+		env          string
+		wantEnv      string
+		wantDBUrl    string
+		migrationDir string
+		wantErr      string
+	}{
+		{
+			name:         "default env",
+			path:         "config/pgmigrant.hcl",
+			wantEnv:      "dev",
+			wantDBUrl:    "postgres://localhost:5432/dev",
+			migrationDir: "./db/migrations",
+		},
+		{
+			name:      "selected env",
+			path:      "./config/pgmigrant.hcl",
+			env:       "prod",
+			wantEnv:   "prod",
+			wantDBUrl: "postgres://db.internal:5432/prod",
+		},
+		{
+			name:      "single env",
+			path:      "single/pgmigrant.hcl",
+			wantEnv:   "only",
+			wantDBUrl: "postgres://localhost/only",
+		},
+		{
+			name:    "unknown env",
+			path:    "config/pgmigrant.hcl",
+			env:     "staging",
+			wantErr: `environment "staging" not found in config`,
+		},
+		{
+			name:    "missing file",
+			path:    "pgmigrant.hcl",
+			wantErr: "failed to read config file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := GetConfigFS(fsys, tt.path, tt.env, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GetConfigFS() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetConfigFS() error = %v", err)
+			}
+			if conf.SelectedEnv.Name != tt.wantEnv {
+				t.Errorf("selected env = %q, want %q", conf.SelectedEnv.Name, tt.wantEnv)
+			}
+			if conf.GetDBUrl() != tt.wantDBUrl {
+				t.Errorf("GetDBUrl() = %q, want %q", conf.GetDBUrl(), tt.wantDBUrl)
+			}
+			if conf.GetMigrationDir() != tt.migrationDir {
+				t.Errorf("GetMigrationDir() = %q, want %q", conf.GetMigrationDir(), tt.migrationDir)
+			}
+			if conf.GetFS() == nil {
+				t.Errorf("GetFS() = nil, want the file system the config was read from")
+			}
+		})
+	}
+}
+
+func TestLoadConfigFSMigrationFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"pgmigrant.hcl":            {Data: []byte(testConfig)},
+		"db/migrations/0001_a.sql": {Data: []byte("CREATE TABLE a (id int);")},
+		"db/migrations/0002_b.sql": {Data: []byte("CREATE TABLE b (id int);")},
+		"db/schema/a.sql":          {Data: []byte("CREATE TABLE a (id int);")},
+		"elsewhere/0003_c.sql":     {Data: []byte("CREATE TABLE c (id int);")},
+	}
+	loaded, err := LoadConfigFS(fsys, "pgmigrant.hcl", nil)
+	if err != nil {
+		t.Fatalf("LoadConfigFS() error = %v", err)
+	}
+	conf, err := loaded.Select("dev")
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	files, err := conf.GetMigrationFiles()
+	if err != nil {
+		t.Fatalf("GetMigrationFiles() error = %v", err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	if want := []string{"0001_a.sql", "0002_b.sql"}; !slices.Equal(names, want) {
+		t.Errorf("GetMigrationFiles() = %q, want %q", names, want)
+	}
+}
+
+func TestValidateFS(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  []string
+	}{
+		{
+			name: "paths exist",
+			files: fstest.MapFS{
+				"db/migrations/0001.sql": {},
+				"db/schema/a.sql":        {},
+			},
+		},
+		{
+			name: "missing paths",
+			files: fstest.MapFS{
+				"db/migrations":       {Data: []byte("not a directory")},
+				"db/schema/README.md": {},
+			},
+			want: []string{
+				`environment "dev": migration_dir: ./db/migrations is not a directory`,
+				`environment "dev": schema_files: directory "db/schema" contains no .sql files`,
+				`environment "prod": schema_files: pattern "db/schema/*.sql" matches no .sql files`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.files["pgmigrant.hcl"] = &fstest.MapFile{Data: []byte(testConfig)}
+			conf, err := LoadConfigFS(tt.files, "pgmigrant.hcl", nil)
+			if err != nil {
+				t.Fatalf("LoadConfigFS() error = %v", err)
+			}
+			var got []string
+			for _, err := range conf.Validate() {
+				got = append(got, err.Error())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffConfig tunes the plans generated for migrations. Unset options keep their default: new tables are
+// data packed, column order changes are ignored, foreign keys on existing tables are added valid,
+// indexes are built and dropped concurrently, no hazard is ignored and plans are validated on a temp
+// database.
+type DiffConfig struct {
+	DataPackNewTables   *bool    `hcl:"data_pack_new_tables,optional"`
+	RespectColumnOrder  *bool    `hcl:"respect_column_order,optional"`
+	NotValidForeignKeys *bool    `hcl:"not_valid_foreign_keys,optional"`
+	ConcurrentIndexes   *bool    `hcl:"concurrent_indexes,optional"`
+	IgnoreHazards       []string `hcl:"ignore_hazards,optional"`
+	ValidatePlan        *bool    `hcl:"validate_plan,optional"`
+}
+
+func (d DiffConfig) GetDataPackNewTables() bool {
+	return boolOr(d.DataPackNewTables, true)
+}
+
+func (d DiffConfig) GetRespectColumnOrder() bool {
+	return boolOr(d.RespectColumnOrder, false)
+}
+
+func (d DiffConfig) GetNotValidForeignKeys() bool {
+	return boolOr(d.NotValidForeignKeys, false)
+}
+
+func (d DiffConfig) GetConcurrentIndexes() bool {
+	return boolOr(d.ConcurrentIndexes, true)
+}
+
+func (d DiffConfig) GetValidatePlan() bool {
+	return boolOr(d.ValidatePlan, true)
+}
+
+// Override returns the config with the options set in o replacing its own.
+func (d DiffConfig) Override(o DiffConfig) DiffConfig {
+	for _, opt := range []struct{ dst, src **bool }{
+		{&d.DataPackNewTables, &o.DataPackNewTables},
+		{&d.RespectColumnOrder, &o.RespectColumnOrder},
+		{&d.NotValidForeignKeys, &o.NotValidForeignKeys},
+		{&d.ConcurrentIndexes, &o.ConcurrentIndexes},
+		{&d.ValidatePlan, &o.ValidatePlan},
+	} {
+		if *opt.src != nil {
+			*opt.dst = *opt.src
+		}
+	}
+	if o.IgnoreHazards != nil {
+		d.IgnoreHazards = o.IgnoreHazards
+	}
+	return d
+}
+
+// String lists the options that are set, e.g. data_pack_new_tables=false.
+func (d DiffConfig) String() string {
+	var opts []string
+	for _, opt := range []struct {
+		name  string
+		value *bool
+	}{
+		{"data_pack_new_tables", d.DataPackNewTables},
+		{"respect_column_order", d.RespectColumnOrder},
+		{"not_valid_foreign_keys", d.NotValidForeignKeys},
+		{"concurrent_indexes", d.ConcurrentIndexes},
+		{"validate_plan", d.ValidatePlan},
+	} {
+		if opt.value != nil {
+			opts = append(opts, fmt.Sprintf("%s=%t", opt.name, *opt.value))
+		}
+	}
+	if d.IgnoreHazards != nil {
+		opts = append(opts, fmt.Sprintf("ignore_hazards=%q", d.IgnoreHazards))
+	}
+	return strings.Join(opts, " ")
+}
+
+func boolOr(b *bool, def bool) bool {
+	if b == nil {
+		return def
+	}
+	return *b
+}
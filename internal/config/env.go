@@ -0,0 +1,234 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	defaultsBlock = "defaults"
+	envBlock      = "env"
+)
+
+// Setting is a resolved env setting along with the block it was set in.
+type Setting struct {
+	Name   string
+	Value  any
+	Origin string
+}
+
+// Settings returns every setting of the env in declaration order. Settings that were never set have
+// an empty origin.
+func (e Env) Settings() []Setting {
+	var settings []Setting
+	v := reflect.ValueOf(e)
+	for i := 0; i < v.NumField(); i++ {
+		name, ok := settingName(v.Type().Field(i))
+		if !ok {
+			continue
+		}
+		settings = append(settings, Setting{
+			Name:   name,
+			Value:  v.Field(i).Interface(),
+			Origin: e.Origins[name],
+		})
+	}
+	return settings
+}
+
+// resolveEnvs decodes every env block, merging in the defaults block and the env it extends.
+//
+// Settings are resolved in order: the defaults block, then the extended env (recursively), then the env
+// itself. A setting defined at a later level replaces the earlier value as a whole: lists and objects
+// are not merged.
+func resolveEnvs(content *hcl.BodyContent, evalCtx *hcl.EvalContext) ([]Env, error) {
+	r := envResolver{
+		evalCtx:  evalCtx,
+		blocks:   make(map[string]*hcl.Block),
+		resolved: make(map[string]*Env),
+		visiting: make(map[string]bool),
+	}
+
+	defaults := content.Blocks.OfType(defaultsBlock)
+	if len(defaults) > 1 {
+		return nil, fmt.Errorf("only one %s block is allowed", defaultsBlock)
+	}
+	if len(defaults) == 1 {
+		r.defaults = defaults[0]
+	}
+
+	var names []string
+	for _, block := range content.Blocks.OfType(envBlock) {
+		name := block.Labels[0]
+		if _, ok := r.blocks[name]; ok {
+			return nil, fmt.Errorf("duplicate environment %q", name)
+		}
+		r.blocks[name] = block
+		names = append(names, name)
+	}
+
+	envs := make([]Env, 0, len(names))
+	for _, name := range names {
+		e, err := r.resolve(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.validateRequired(); err != nil {
+			return nil, err
+		}
+		envs = append(envs, *e)
+	}
+	return envs, nil
+}
+
+type envResolver struct {
+	evalCtx  *hcl.EvalContext
+	defaults *hcl.Block
+	blocks   map[string]*hcl.Block
+	resolved map[string]*Env
+	visiting map[string]bool
+}
+
+func (r *envResolver) resolve(name string) (*Env, error) {
+	if e, ok := r.resolved[name]; ok {
+		return e, nil
+	}
+	block, ok := r.blocks[name]
+	if !ok {
+		return nil, fmt.Errorf("environment %q not found in config", name)
+	}
+	if r.visiting[name] {
+		return nil, fmt.Errorf("environment %q is part of an extends cycle", name)
+	}
+	r.visiting[name] = true
+	defer delete(r.visiting, name)
+
+	var layer Env
+	present, err := decodeEnvBody(block.Body, r.evalCtx, &layer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode environment %q: %w", name, err)
+	}
+
+	var e Env
+	if present["extends"] {
+		parent, err := extendedEnv(layer.Extends)
+		if err != nil {
+			return nil, fmt.Errorf("environment %q: %w", name, err)
+		}
+		base, err := r.resolve(parent)
+		if err != nil {
+			return nil, fmt.Errorf("environment %q: %w", name, err)
+		}
+		e = base.clone()
+	} else if r.defaults != nil {
+		var defaults Env
+		defaultsPresent, err := decodeEnvBody(r.defaults.Body, r.evalCtx, &defaults)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s block: %w", defaultsBlock, err)
+		}
+		if defaultsPresent["extends"] {
+			return nil, fmt.Errorf("the %s block cannot extend an environment", defaultsBlock)
+		}
+		e.merge(defaults, defaultsPresent, defaultsBlock)
+	}
+	e.merge(layer, present, envBlock+"."+name)
+	e.Name = name
+	e.Extends = layer.Extends
+
+	r.resolved[name] = &e
+	return &e, nil
+}
+
+// decodeEnvBody decodes body into e and reports which settings it defines.
+func decodeEnvBody(body hcl.Body, evalCtx *hcl.EvalContext, e *Env) (map[string]bool, error) {
+	if diags := gohcl.DecodeBody(body, evalCtx, e); diags.HasErrors() {
+		return nil, diags
+	}
+	schema, _ := gohcl.ImpliedBodySchema(e)
+	content, _, diags := body.PartialContent(schema)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	present := make(map[string]bool)
+	for name := range content.Attributes {
+		present[name] = true
+	}
+	for _, block := range content.Blocks {
+		present[block.Type] = true
+	}
+	return present, nil
+}
+
+// extendedEnv returns the name of the env referenced by an extends attribute, written either as
+// env.<name> or as a string.
+func extendedEnv(expr hcl.Expression) (string, error) {
+	if traversal, diags := hcl.AbsTraversalForExpr(expr); !diags.HasErrors() {
+		if len(traversal) == 2 && traversal.RootName() == envBlock {
+			if attr, ok := traversal[1].(hcl.TraverseAttr); ok {
+				return attr.Name, nil
+			}
+		}
+		return "", fmt.Errorf("extends must reference an environment as %s.<name>", envBlock)
+	}
+	val, diags := expr.Value(nil)
+	if diags.HasErrors() || val.IsNull() || val.Type() != cty.String {
+		return "", fmt.Errorf("extends must reference an environment as %s.<name>", envBlock)
+	}
+	return val.AsString(), nil
+}
+
+// merge copies the settings of src marked as present into e, recording origin for each of them.
+func (e *Env) merge(src Env, present map[string]bool, origin string) {
+	if e.Origins == nil {
+		e.Origins = make(map[string]string)
+	}
+	dst := reflect.ValueOf(e).Elem()
+	v := reflect.ValueOf(src)
+	for i := 0; i < v.NumField(); i++ {
+		name, ok := settingName(v.Type().Field(i))
+		if !ok || !present[name] {
+			continue
+		}
+		dst.Field(i).Set(v.Field(i))
+		e.Origins[name] = origin
+	}
+}
+
+func (e *Env) clone() Env {
+	c := *e
+	c.Origins = make(map[string]string, len(e.Origins))
+	for k, v := range e.Origins {
+		c.Origins[k] = v
+	}
+	return c
+}
+
+func (e *Env) validateRequired() error {
+	for _, name := range []string{"db_url", "schema_files"} {
+		if e.Origins[name] == "" {
+			return fmt.Errorf("environment %q: %s is required", e.Name, name)
+		}
+	}
+	return nil
+}
+
+// settingName returns the HCL name of an env field, excluding labels and the extends attribute.
+func settingName(field reflect.StructField) (string, bool) {
+	tag, ok := field.Tag.Lookup("hcl")
+	if !ok {
+		return "", false
+	}
+	parts := strings.Split(tag, ",")
+	if len(parts) > 1 && parts[1] == "label" {
+		return "", false
+	}
+	if parts[0] == "extends" {
+		return "", false
+	}
+	return parts[0], true
+}
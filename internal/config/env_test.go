@@ -0,0 +1,317 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// loadTestConfig loads the config file src with vars.
+func loadTestConfig(src string, vars Vars) (*Config, error) {
+	return LoadConfigFS(fstest.MapFS{"pgmigrant.hcl": {Data: []byte(src)}}, "pgmigrant.hcl", vars)
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+const inheritanceConfig = `
+defaults {
+  migration_dir   = "db/migrations"
+  schema_files    = ["db/schema/"]
+  exclude_schemas = ["audit", "cron"]
+  github_config = {
+    owner         = "acme"
+    repo          = "api"
+    target_branch = "main"
+  }
+  diff {
+    data_pack_new_tables = false
+    ignore_hazards       = ["INDEX_BUILD"]
+  }
+}
+
+env "dev" {
+  db_url = "postgres://localhost:5432/dev"
+}
+
+env "staging" {
+  extends         = env.dev
+  db_url          = "postgres://staging:5432/app"
+  exclude_schemas = ["cron"]
+  diff {
+    concurrent_indexes = false
+  }
+}
+
+env "prod" {
+  extends        = "staging"
+  db_url         = "postgres://prod:5432/app"
+  allow_db_clean = false
+}
+
+env "local" {
+  db_url       = "postgres://localhost:5432/local"
+  schema_files = ["schema.sql"]
+  github_config = {
+    owner         = "me"
+    repo          = "fork"
+    target_branch = "dev"
+  }
+}
+`
+
+func TestResolveEnvs(t *testing.T) {
+	conf, err := loadTestConfig(inheritanceConfig, nil)
+	if err != nil {
+		t.Fatalf("LoadConfigFS() error = %v", err)
+	}
+	envs := make(map[string]Env)
+	for _, e := range conf.Envs {
+		envs[e.Name] = e
+	}
+	defaultDiff := &DiffConfig{DataPackNewTables: boolPtr(false), IgnoreHazards: []string{"INDEX_BUILD"}}
+	stagingDiff := &DiffConfig{ConcurrentIndexes: boolPtr(false)}
+	acme := GitHubConfig{Owner: "acme", Repo: "api", TargetBranch: "main"}
+
+	tests := []struct {
+		env  string
+		want Env
+	}{
+		{
+			env: "dev",
+			want: Env{
+				DBUrl:          "postgres://localhost:5432/dev",
+				MigrationDir:   "db/migrations",
+				SchemaFiles:    []string{"db/schema/"},
+				ExcludeSchemas: []string{"audit", "cron"},
+				GitHubConfig:   acme,
+				Diff:           defaultDiff,
+				Origins: map[string]string{
+					"db_url":          "env.dev",
+					"migration_dir":   "defaults",
+					"schema_files":    "defaults",
+					"exclude_schemas": "defaults",
+					"github_config":   "defaults",
+					"diff":            "defaults",
+				},
+			},
+		},
+		{
+			// Lists and blocks set by an env replace the inherited ones as a whole.
+			env: "staging",
+			want: Env{
+				DBUrl:          "postgres://staging:5432/app",
+				MigrationDir:   "db/migrations",
+				SchemaFiles:    []string{"db/schema/"},
+				ExcludeSchemas: []string{"cron"},
+				GitHubConfig:   acme,
+				Diff:           stagingDiff,
+				Origins: map[string]string{
+					"db_url":          "env.staging",
+					"migration_dir":   "defaults",
+					"schema_files":    "defaults",
+					"exclude_schemas": "env.staging",
+					"github_config":   "defaults",
+					"diff":            "env.staging",
+				},
+			},
+		},
+		{
+			env: "prod",
+			want: Env{
+				DBUrl:          "postgres://prod:5432/app",
+				MigrationDir:   "db/migrations",
+				SchemaFiles:    []string{"db/schema/"},
+				ExcludeSchemas: []string{"cron"},
+				GitHubConfig:   acme,
+				Diff:           stagingDiff,
+				Origins: map[string]string{
+					"db_url":          "env.prod",
+					"migration_dir":   "defaults",
+					"schema_files":    "defaults",
+					"exclude_schemas": "env.staging",
+					"github_config":   "defaults",
+					"diff":            "env.staging",
+					"allow_db_clean":  "env.prod",
+				},
+			},
+		},
+		{
+			// Objects are replaced as a whole too, rather than merged field by field.
+			env: "local",
+			want: Env{
+				DBUrl:          "postgres://localhost:5432/local",
+				MigrationDir:   "db/migrations",
+				SchemaFiles:    []string{"schema.sql"},
+				ExcludeSchemas: []string{"audit", "cron"},
+				GitHubConfig:   GitHubConfig{Owner: "me", Repo: "fork", TargetBranch: "dev"},
+				Diff:           defaultDiff,
+				Origins: map[string]string{
+					"db_url":          "env.local",
+					"migration_dir":   "defaults",
+					"schema_files":    "env.local",
+					"exclude_schemas": "defaults",
+					"github_config":   "env.local",
+					"diff":            "defaults",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			got, ok := envs[tt.env]
+			if !ok {
+				t.Fatalf("environment %q not loaded", tt.env)
+			}
+			got.Name, got.Extends = "", nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolved env = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveEnvsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		wantErr string
+	}{
+		{
+			name:    "db_url is required",
+			src:     `env "dev" { schema_files = ["schema.sql"] }`,
+			wantErr: `environment "dev": db_url is required`,
+		},
+		{
+			name:    "schema_files is required",
+			src:     `env "dev" { db_url = "postgres://localhost/dev" }`,
+			wantErr: `environment "dev": schema_files is required`,
+		},
+		{
+			name: "empty values count as set",
+			src: `
+env "dev" {
+  db_url       = ""
+  schema_files = []
+}`,
+		},
+		{
+			name: "required settings can be inherited",
+			src: `
+defaults {
+  schema_files = ["schema.sql"]
+}
+
+env "dev" {
+  db_url = "postgres://localhost/dev"
+}
+
+env "prod" {
+  extends = env.dev
+}`,
+		},
+		{
+			name: "extends cycle",
+			src: `
+env "a" {
+  extends = env.b
+}
+
+env "b" {
+  extends = env.a
+}`,
+			wantErr: `environment "a" is part of an extends cycle`,
+		},
+		{
+			name: "unknown extended env",
+			src: `
+env "prod" {
+  extends = env.dev
+}`,
+			wantErr: `environment "prod": environment "dev" not found in config`,
+		},
+		{
+			name: "invalid extends",
+			src: `
+env "prod" {
+  extends = env.dev.name
+}`,
+			wantErr: "extends must reference an environment as env.<name>",
+		},
+		{
+			name: "duplicate env",
+			src: `
+env "dev" {
+  db_url = "postgres://localhost/dev"
+}
+
+env "dev" {
+  db_url = "postgres://localhost/other"
+}`,
+			wantErr: `duplicate environment "dev"`,
+		},
+		{
+			name:    "several defaults blocks",
+			src:     "defaults {\n}\n\ndefaults {\n}\n",
+			wantErr: "only one defaults block is allowed",
+		},
+		{
+			name: "defaults extending an env",
+			src: `
+defaults {
+  extends = env.dev
+}
+
+env "dev" {
+  db_url       = "postgres://localhost/dev"
+  schema_files = ["schema.sql"]
+}`,
+			wantErr: "the defaults block cannot extend an environment",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadTestConfig(tt.src, nil)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("LoadConfigFS() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("LoadConfigFS() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnvSettings(t *testing.T) {
+	conf, err := loadTestConfig(inheritanceConfig, nil)
+	if err != nil {
+		t.Fatalf("LoadConfigFS() error = %v", err)
+	}
+	selected, err := conf.Select("staging")
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	var names []string
+	origins := make(map[string]string)
+	for _, s := range selected.SelectedEnv.Settings() {
+		names = append(names, s.Name)
+		origins[s.Name] = s.Origin
+	}
+	wantNames := []string{
+		"db_url", "migration_dir", "repeatable_dir", "schema_files", "github_config", "include_schemas",
+		"exclude_schemas", "allow_db_clean", "diff", "metrics", "notify", "hooks",
+	}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("Settings() names = %q, want %q", names, wantNames)
+	}
+	for name, want := range map[string]string{"db_url": "env.staging", "migration_dir": "defaults", "repeatable_dir": ""} {
+		if origins[name] != want {
+			t.Errorf("origin of %s = %q, want %q", name, origins[name], want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Policies for failing hooks.
+const (
+	// HookFail fails the run. Failing on_failure hooks are logged as errors, as the run already failed.
+	HookFail = "fail"
+	// HookWarn logs a warning and goes on.
+	HookWarn = "warn"
+	// HookIgnore goes on silently.
+	HookIgnore = "ignore"
+)
+
+// HookPolicies lists the values of on_error.
+var HookPolicies = []string{HookFail, HookWarn, HookIgnore}
+
+// HooksConfig lists the hooks run by apply when migrations are pending: before applying them, after
+// each of them, after all of them, and when the run fails. The hooks of a phase run in order.
+type HooksConfig struct {
+	BeforeApply []HookConfig `hcl:"before_apply,block"`
+	AfterEach   []HookConfig `hcl:"after_each,block"`
+	AfterApply  []HookConfig `hcl:"after_apply,block"`
+	OnFailure   []HookConfig `hcl:"on_failure,block"`
+}
+
+// HookConfig is a hook, either SQL executed on the database or a shell command. OnError defaults to
+// HookFail, and Timeout, a Go duration, to none.
+type HookConfig struct {
+	SQL     string `hcl:"sql,optional"`
+	Command string `hcl:"command,optional"`
+	OnError string `hcl:"on_error,optional"`
+	Timeout string `hcl:"timeout,optional"`
+}
+
+func (h HookConfig) GetOnError() string {
+	if h.OnError == "" {
+		return HookFail
+	}
+	return h.OnError
+}
+
+// GetTimeout returns the timeout of the hook, or 0 if it has none.
+func (h HookConfig) GetTimeout() time.Duration {
+	d, _ := time.ParseDuration(h.Timeout)
+	return d
+}
+
+// Phases returns the hooks of each phase, by the name of its block.
+func (h HooksConfig) Phases() []struct {
+	Name  string
+	Hooks []HookConfig
+} {
+	return []struct {
+		Name  string
+		Hooks []HookConfig
+	}{
+		{"before_apply", h.BeforeApply},
+		{"after_each", h.AfterEach},
+		{"after_apply", h.AfterApply},
+		{"on_failure", h.OnFailure},
+	}
+}
+
+// String lists the number of hooks of each phase.
+func (h HooksConfig) String() string {
+	var opts []string
+	for _, phase := range h.Phases() {
+		if len(phase.Hooks) > 0 {
+			opts = append(opts, fmt.Sprintf("%s=%d", phase.Name, len(phase.Hooks)))
+		}
+	}
+	return strings.Join(opts, " ")
+}
+
+func (h HooksConfig) validate() []error {
+	var errs []error
+	for _, phase := range h.Phases() {
+		for i, hook := range phase.Hooks {
+			name := fmt.Sprintf("hooks: %s hook %d", phase.Name, i+1)
+			if (hook.SQL == "") == (hook.Command == "") {
+				errs = append(errs, fmt.Errorf("%s must set exactly one of sql and command", name))
+			}
+			if !slices.Contains(HookPolicies, hook.GetOnError()) {
+				errs = append(errs, fmt.Errorf("%s: unknown on_error %q, expected one of %q", name, hook.OnError, HookPolicies))
+			}
+			if hook.Timeout != "" {
+				if d, err := time.ParseDuration(hook.Timeout); err != nil || d <= 0 {
+					errs = append(errs, fmt.Errorf("%s: timeout must be a positive duration, such as 30s", name))
+				}
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DefaultMetricsJob is the job metrics are pushed under when the metrics block sets none.
+const DefaultMetricsJob = "pg-migrant"
+
+// MetricsConfig sets where the metrics of migration runs are exported, in the Prometheus text format.
+// Both destinations are optional: Textfile is a file for the textfile collector of the node exporter, and
+// PushURL the base URL of a Pushgateway.
+type MetricsConfig struct {
+	Textfile string `hcl:"textfile,optional"`
+	PushURL  string `hcl:"push_url,optional"`
+	Job      string `hcl:"job,optional"`
+}
+
+// Enabled reports whether metrics are exported anywhere.
+func (m MetricsConfig) Enabled() bool {
+	return m.Textfile != "" || m.PushURL != ""
+}
+
+func (m MetricsConfig) GetJob() string {
+	if m.Job == "" {
+		return DefaultMetricsJob
+	}
+	return m.Job
+}
+
+// Override returns the config with the options set in o replacing its own.
+func (m MetricsConfig) Override(o MetricsConfig) MetricsConfig {
+	for _, opt := range []struct{ dst, src *string }{
+		{&m.Textfile, &o.Textfile},
+		{&m.PushURL, &o.PushURL},
+		{&m.Job, &o.Job},
+	} {
+		if *opt.src != "" {
+			*opt.dst = *opt.src
+		}
+	}
+	return m
+}
+
+// String lists the options that are set, with the password of push_url masked.
+func (m MetricsConfig) String() string {
+	var opts []string
+	if m.Textfile != "" {
+		opts = append(opts, fmt.Sprintf("textfile=%q", m.Textfile))
+	}
+	if m.PushURL != "" {
+		pushURL := "<invalid url>"
+		if u, err := url.Parse(m.PushURL); err == nil {
+			pushURL = u.Redacted()
+		}
+		opts = append(opts, fmt.Sprintf("push_url=%q", pushURL))
+	}
+	if m.Job != "" {
+		opts = append(opts, fmt.Sprintf("job=%q", m.Job))
+	}
+	return strings.Join(opts, " ")
+}
+
+func (m MetricsConfig) validate() []error {
+	var errs []error
+	if m.PushURL != "" {
+		if u, err := url.Parse(m.PushURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, errors.New("metrics: push_url must be an http or https URL"))
+		}
+	}
+	if strings.Contains(m.Job, "/") {
+		errs = append(errs, errors.New("metrics: job cannot contain /"))
+	}
+	return errs
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/cortea-ai/pg-migrant/internal/notify"
+)
+
+// NotifyConfig lists the webhooks the events of apply are posted to. With DriftCheck, the schema of the
+// database is also compared to the schema files after each apply, which requires a temp database.
+type NotifyConfig struct {
+	Webhooks   []WebhookConfig `hcl:"webhook,block"`
+	DriftCheck bool            `hcl:"drift_check,optional"`
+}
+
+// WebhookConfig is a webhook of the notify block. Events defaults to every event and Template to a
+// Slack-compatible payload.
+type WebhookConfig struct {
+	Name     string            `hcl:"name,label"`
+	URL      string            `hcl:"url"`
+	Events   []string          `hcl:"events,optional"`
+	Template string            `hcl:"template,optional"`
+	Headers  map[string]string `hcl:"headers,optional"`
+}
+
+// NotifyWebhooks returns the webhooks of the config.
+func (n NotifyConfig) NotifyWebhooks() []notify.Webhook {
+	webhooks := make([]notify.Webhook, 0, len(n.Webhooks))
+	for _, w := range n.Webhooks {
+		webhooks = append(webhooks, notify.Webhook(w))
+	}
+	return webhooks
+}
+
+// String lists the webhooks with their events, leaving out their URLs, which are often secrets.
+func (n NotifyConfig) String() string {
+	var opts []string
+	for _, w := range n.Webhooks {
+		events := w.Events
+		if len(events) == 0 {
+			events = notify.Events
+		}
+		opts = append(opts, fmt.Sprintf("webhook %q events=%q", w.Name, events))
+	}
+	if n.DriftCheck {
+		opts = append(opts, "drift_check=true")
+	}
+	return strings.Join(opts, " ")
+}
+
+func (n NotifyConfig) validate() []error {
+	var errs []error
+	seen := make(map[string]bool)
+	for _, w := range n.Webhooks {
+		if seen[w.Name] {
+			errs = append(errs, fmt.Errorf("notify: duplicate webhook %q", w.Name))
+		}
+		seen[w.Name] = true
+		if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("notify: url of webhook %q must be an http or https URL", w.Name))
+		}
+		for _, event := range w.Events {
+			if !slices.Contains(notify.Events, event) {
+				errs = append(errs, fmt.Errorf("notify: unknown event %q in webhook %q, expected one of %q", event, w.Name, notify.Events))
+			}
+		}
+		if w.Template != "" {
+			if _, err := notify.ParseTemplate(w.Template); err != nil {
+				errs = append(errs, fmt.Errorf("notify: template of webhook %q: %w", w.Name, err))
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Redacted replaces sensitive values in output.
+const Redacted = "(sensitive value)"
+
+// minSensitiveLength is the length below which sensitive values are not redacted: a value as short as
+// "p" would also match within keywords and URL schemes, wrecking unrelated output.
+const minSensitiveLength = 6
+
+var sensitive = struct {
+	sync.Mutex
+	values []string
+}{}
+
+// Redact replaces every sensitive value known to the config in s, including their URL-escaped forms.
+// Values shorter than minSensitiveLength are left as they are.
+func Redact(s string) string {
+	sensitive.Lock()
+	defer sensitive.Unlock()
+	for _, value := range sensitive.values {
+		s = strings.ReplaceAll(s, value, Redacted)
+	}
+	return s
+}
+
+// registerSensitive marks every string within val as sensitive.
+func registerSensitive(val cty.Value) {
+	_ = cty.Walk(val, func(_ cty.Path, v cty.Value) (bool, error) {
+		if v.IsKnown() && !v.IsNull() && v.Type() == cty.String {
+			registerSensitiveString(v.AsString())
+		}
+		return true, nil
+	})
+}
+
+func registerSensitiveString(value string) {
+	if len(value) < minSensitiveLength {
+		return
+	}
+	sensitive.Lock()
+	defer sensitive.Unlock()
+	for _, v := range []string{value, url.PathEscape(value), url.QueryEscape(value)} {
+		if !slices.Contains(sensitive.values, v) {
+			sensitive.values = append(sensitive.values, v)
+		}
+	}
+	// Replace longer values first so that a secret containing another one is fully redacted.
+	sort.Slice(sensitive.values, func(i, j int) bool {
+		return len(sensitive.values[i]) > len(sensitive.values[j])
+	})
+}
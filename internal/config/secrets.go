@@ -0,0 +1,192 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/jackc/pgpassfile"
+	"github.com/jackc/pgservicefile"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// secretFuncs returns the HCL functions reading credentials from outside the config file. Their
+// results are cached for the lifetime of the returned functions and registered as sensitive.
+func secretFuncs() map[string]function.Function {
+	cache := make(map[string]cty.Value)
+	cached := func(key string, fn func() (string, error)) (cty.Value, error) {
+		if val, ok := cache[key]; ok {
+			return val, nil
+		}
+		secret, err := fn()
+		if err != nil {
+			return cty.NilVal, err
+		}
+		registerSensitiveString(secret)
+		val := cty.StringVal(secret)
+		cache[key] = val
+		return val, nil
+	}
+
+	return map[string]function.Function{
+		"file": function.New(&function.Spec{
+			Params: []function.Parameter{
+				{Name: "path", Type: cty.String},
+			},
+			Type: function.StaticReturnType(cty.String),
+			Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+				path := args[0].AsString()
+				return cached("file\x00"+path, func() (string, error) {
+					return readSecretFile(path)
+				})
+			},
+		}),
+		"pgpass": function.New(&function.Spec{
+			Params: []function.Parameter{
+				{Name: "host", Type: cty.String},
+				{Name: "port", Type: cty.String},
+				{Name: "dbname", Type: cty.String},
+				{Name: "user", Type: cty.String},
+			},
+			Type: function.StaticReturnType(cty.String),
+			Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+				host, port, dbname, user := args[0].AsString(), args[1].AsString(), args[2].AsString(), args[3].AsString()
+				return cached(strings.Join([]string{"pgpass", host, port, dbname, user}, "\x00"), func() (string, error) {
+					return lookupPgpass(host, port, dbname, user)
+				})
+			},
+		}),
+		"pg_service": function.New(&function.Spec{
+			Params: []function.Parameter{
+				{Name: "name", Type: cty.String},
+			},
+			Type: function.StaticReturnType(cty.String),
+			Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+				name := args[0].AsString()
+				return cached("pg_service\x00"+name, func() (string, error) {
+					return lookupPgService(name)
+				})
+			},
+		}),
+		"exec": function.New(&function.Spec{
+			Params: []function.Parameter{
+				{Name: "command", Type: cty.String},
+			},
+			VarParam: &function.Parameter{
+				Name: "args",
+				Type: cty.String,
+			},
+			Type: function.StaticReturnType(cty.String),
+			Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+				argv := make([]string, 0, len(args))
+				for _, arg := range args {
+					argv = append(argv, arg.AsString())
+				}
+				return cached("exec\x00"+strings.Join(argv, "\x00"), func() (string, error) {
+					return runCredentialHelper(argv)
+				})
+			},
+		}),
+	}
+}
+
+// readSecretFile reads a secret such as a Kubernetes-mounted one, without its trailing newline.
+func readSecretFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading secret file: %w", err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// lookupPgpass returns the password matching the given connection parameters in the file pointed to by
+// PGPASSFILE, or ~/.pgpass.
+func lookupPgpass(host, port, dbname, user string) (string, error) {
+	path := os.Getenv("PGPASSFILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("locating .pgpass: %w", err)
+		}
+		path = filepath.Join(home, ".pgpass")
+	}
+	passfile, err := pgpassfile.ReadPassfile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", path, err)
+	}
+	password := passfile.FindPassword(host, port, dbname, user)
+	if password == "" {
+		return "", fmt.Errorf("no password for %s@%s:%s/%s in %s", user, host, port, dbname, path)
+	}
+	return password, nil
+}
+
+// lookupPgService returns a connection URL for a service defined in the file pointed to by
+// PGSERVICEFILE, or ~/.pg_service.conf.
+func lookupPgService(name string) (string, error) {
+	path := os.Getenv("PGSERVICEFILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("locating .pg_service.conf: %w", err)
+		}
+		path = filepath.Join(home, ".pg_service.conf")
+	}
+	servicefile, err := pgservicefile.ReadServicefile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", path, err)
+	}
+	service, err := servicefile.GetService(name)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	settings := make(map[string]string, len(service.Settings))
+	for k, v := range service.Settings {
+		settings[k] = v
+	}
+	take := func(key string) string {
+		v := settings[key]
+		delete(settings, key)
+		return v
+	}
+	u := url.URL{Scheme: "postgres", Host: take("host")}
+	if port := take("port"); port != "" {
+		u.Host = net.JoinHostPort(u.Host, port)
+	}
+	if dbname := take("dbname"); dbname != "" {
+		u.Path = "/" + dbname
+	}
+	user, password := take("user"), take("password")
+	if password != "" {
+		registerSensitiveString(password)
+		u.User = url.UserPassword(user, password)
+	} else if user != "" {
+		u.User = url.User(user)
+	}
+	query := url.Values{}
+	for k, v := range settings {
+		query.Set(k, v)
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// runCredentialHelper runs a command, such as a vault CLI, and returns its trimmed output.
+func runCredentialHelper(argv []string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// The output is deliberately left out, as it might contain part of the credential.
+		return "", fmt.Errorf("credential helper %q failed: %w", argv[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cortea-ai/pg-migrant/internal/db"
+	"github.com/cortea-ai/pg-migrant/internal/diffutils"
+	"github.com/cortea-ai/pg-migrant/internal/fsutil"
+	"github.com/jackc/pgx/v4"
+	"github.com/stripe/pg-schema-diff/pkg/diff"
+)
+
+// hazardTypes lists the migration hazards pg-schema-diff reports.
+var hazardTypes = []string{
+	diff.MigrationHazardTypeAcquiresAccessExclusiveLock,
+	diff.MigrationHazardTypeAcquiresShareLock,
+	diff.MigrationHazardTypeAcquiresShareRowExclusiveLock,
+	diff.MigrationHazardTypeCorrectness,
+	diff.MigrationHazardTypeDeletesData,
+	diff.MigrationHazardTypeHasUntrackableDependencies,
+	diff.MigrationHazardTypeIndexBuild,
+	diff.MigrationHazardTypeIndexDropped,
+	diff.MigrationHazardTypeImpactsDatabasePerformance,
+	diff.MigrationHazardTypeIsUserGenerated,
+	diff.MigrationHazardTypeExtensionVersionUpgrade,
+	diff.MigrationHazardTypeAuthzUpdate,
+}
+
+// Validate checks every env of the config beyond what loading it enforces: that the paths it references
+// exist and the schema files are .sql files, that the connection string parses and that the GitHub
+// config is complete. It returns all the problems found.
+func (conf *Config) Validate() []error {
+	var errs []error
+	if conf.DefaultEnv != "" {
+		found := false
+		for _, e := range conf.Envs {
+			found = found || e.Name == conf.DefaultEnv
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("default_env: environment %q not found in config", conf.DefaultEnv))
+		}
+	}
+	for _, e := range conf.Envs {
+		for _, err := range e.validate(conf.GetFS()) {
+			errs = append(errs, fmt.Errorf("environment %q: %w", e.Name, err))
+		}
+	}
+	return errs
+}
+
+func (e Env) validate(fsys fs.FS) []error {
+	var errs []error
+	if _, err := pgx.ParseConfig(e.DBUrl); err != nil {
+		errs = append(errs, fmt.Errorf("db_url: %w", db.SanitizeParseConfigError(err)))
+	}
+	if e.MigrationDir != "" {
+		if info, err := fs.Stat(fsys, fsutil.Clean(e.MigrationDir)); err != nil {
+			errs = append(errs, fmt.Errorf("migration_dir: %w", err))
+		} else if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("migration_dir: %s is not a directory", e.MigrationDir))
+		}
+	}
+	if e.RepeatableDir != "" {
+		if info, err := fs.Stat(fsys, fsutil.Clean(e.RepeatableDir)); err != nil {
+			errs = append(errs, fmt.Errorf("repeatable_dir: %w", err))
+		} else if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("repeatable_dir: %s is not a directory", e.RepeatableDir))
+		}
+	}
+	for _, entry := range e.SchemaFiles {
+		paths, err := diffutils.ExpandSchemaFiles(fsys, []string{entry})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("schema_files: %w", err))
+			continue
+		}
+		for _, path := range paths {
+			if strings.ToLower(filepath.Ext(path)) != ".sql" {
+				errs = append(errs, fmt.Errorf("schema_files: %s is not a .sql file", path))
+			}
+		}
+	}
+	for _, name := range e.IncludeSchemas {
+		if name == db.PGMigrantSchema {
+			errs = append(errs, fmt.Errorf("include_schemas: %s is reserved for pg-migrant", name))
+		}
+		for _, excluded := range e.ExcludeSchemas {
+			if name == excluded {
+				errs = append(errs, fmt.Errorf("include_schemas: %s is also in exclude_schemas", name))
+			}
+		}
+	}
+	if e.Diff != nil {
+		for _, hazard := range e.Diff.IgnoreHazards {
+			if !slices.Contains(hazardTypes, hazard) {
+				errs = append(errs, fmt.Errorf("diff: unknown hazard type %q in ignore_hazards", hazard))
+			}
+		}
+	}
+	if e.Metrics != nil {
+		errs = append(errs, e.Metrics.validate()...)
+	}
+	if e.Notify != nil {
+		errs = append(errs, e.Notify.validate()...)
+	}
+	if e.Hooks != nil {
+		errs = append(errs, e.Hooks.validate()...)
+	}
+	gh := e.GitHubConfig
+	if gh != (GitHubConfig{}) {
+		for _, field := range []struct{ name, value string }{
+			{"owner", gh.Owner},
+			{"repo", gh.Repo},
+			{"target_branch", gh.TargetBranch},
+		} {
+			if field.value == "" {
+				errs = append(errs, fmt.Errorf("github_config: %s is required", field.name))
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+type Validation struct {
+	Condition    hcl.Expression `hcl:"condition"`
+	ErrorMessage string         `hcl:"error_message"`
+}
+
+// decodeVariable decodes a variable block and resolves its value from vars, falling back to its default.
+func decodeVariable(block *hcl.Block, evalCtx *hcl.EvalContext, vars Vars) (Variable, error) {
+	var v Variable
+	if diags := gohcl.DecodeBody(block.Body, evalCtx, &v); diags.HasErrors() {
+		return v, fmt.Errorf("failed to decode variable '%s': %w", block.Labels[0], diags)
+	}
+	v.Name = block.Labels[0]
+
+	ty := cty.DynamicPseudoType
+	if v.Type != nil {
+		var diags hcl.Diagnostics
+		ty, diags = typeexpr.TypeConstraint(v.Type.Expr)
+		if diags.HasErrors() {
+			return v, fmt.Errorf("invalid type of variable '%s': %w", v.Name, diags)
+		}
+	}
+
+	var val cty.Value
+	if raw, ok := vars[v.Name]; ok {
+		var err error
+		if val, err = parseVarValue(raw, ty); err != nil {
+			return v, fmt.Errorf("invalid value for variable '%s': %w", v.Name, err)
+		}
+	} else if v.Default != cty.NilVal {
+		val = v.Default
+	} else {
+		return v, fmt.Errorf("variable '%s' is required: set it with --var %s=<value>", v.Name, v.Name)
+	}
+
+	val, err := convert.Convert(val, ty)
+	if err != nil {
+		return v, fmt.Errorf("invalid value for variable '%s': expected %s: %w", v.Name, typeexpr.TypeString(ty), err)
+	}
+	if v.Sensitive {
+		registerSensitive(val)
+	}
+	v.Value = val
+	return v, nil
+}
+
+// parseVarValue parses a --var value. Primitive values are taken literally, while collections and
+// objects are parsed as HCL expressions, e.g. --var 'schemas=["public","billing"]'.
+func parseVarValue(raw string, ty cty.Type) (cty.Value, error) {
+	if ty == cty.DynamicPseudoType || ty.IsPrimitiveType() {
+		return cty.StringVal(raw), nil
+	}
+	expr, diags := hclsyntax.ParseExpression([]byte(raw), "<var>", hcl.InitialPos)
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+	val, diags := expr.Value(nil)
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+	return val, nil
+}
+
+// validate evaluates the validation rules of the variable. evalCtx must expose the resolved variables.
+func (v Variable) validate(evalCtx *hcl.EvalContext) error {
+	for _, validation := range v.Validations {
+		result, diags := validation.Condition.Value(evalCtx)
+		if diags.HasErrors() {
+			return fmt.Errorf("failed to evaluate validation of variable '%s': %w", v.Name, diags)
+		}
+		if !result.IsKnown() || result.IsNull() || result.Type() != cty.Bool {
+			return fmt.Errorf("validation condition of variable '%s' must be a boolean", v.Name)
+		}
+		if result.False() {
+			return fmt.Errorf("invalid value for variable '%s': %s", v.Name, validation.ErrorMessage)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package config
+
+import (
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// variableConfig is a config with the given variable blocks and a minimal env.
+func variableConfig(variables string) string {
+	return variables + `
+env "dev" {
+  db_url       = "postgres://localhost/dev"
+  schema_files = ["schema.sql"]
+}
+`
+}
+
+func TestVariables(t *testing.T) {
+	tests := []struct {
+		name      string
+		variables string
+		vars      Vars
+		want      cty.Value
+		wantErr   string
+	}{
+		{
+			name:      "untyped default",
+			variables: `variable "value" { default = "a" }`,
+			want:      cty.StringVal("a"),
+		},
+		{
+			name:      "untyped --var",
+			variables: `variable "value" { default = "a" }`,
+			vars:      Vars{"value": "b"},
+			want:      cty.StringVal("b"),
+		},
+		{
+			name:      "number from --var",
+			variables: `variable "value" { type = number }`,
+			vars:      Vars{"value": "5432"},
+			want:      cty.NumberIntVal(5432),
+		},
+		{
+			name:      "bool default",
+			variables: "variable \"value\" {\n  type    = bool\n  default = true\n}",
+			want:      cty.True,
+		},
+		{
+			name:      "list from --var",
+			variables: `variable "value" { type = list(string) }`,
+			vars:      Vars{"value": `["public", "billing"]`},
+			want:      cty.ListVal([]cty.Value{cty.StringVal("public"), cty.StringVal("billing")}),
+		},
+		{
+			name:      "object default converted to its type",
+			variables: "variable \"value\" {\n  type    = object({ port = number })\n  default = { port = \"5432\" }\n}",
+			want:      cty.ObjectVal(map[string]cty.Value{"port": cty.NumberIntVal(5432)}),
+		},
+		{
+			name:      "later declaration wins",
+			variables: "variable \"value\" {\n  default = \"a\"\n}\n\nvariable \"value\" {\n  default = \"b\"\n}",
+			want:      cty.StringVal("b"),
+		},
+		{
+			name:      "required variable",
+			variables: `variable "value" { type = string }`,
+			wantErr:   "variable 'value' is required: set it with --var value=<value>",
+		},
+		{
+			name:      "invalid number",
+			variables: `variable "value" { type = number }`,
+			vars:      Vars{"value": "five"},
+			wantErr:   "invalid value for variable 'value': expected number",
+		},
+		{
+			name:      "invalid list",
+			variables: `variable "value" { type = list(string) }`,
+			vars:      Vars{"value": `["public"`},
+			wantErr:   "invalid value for variable 'value'",
+		},
+		{
+			name:      "invalid type",
+			variables: `variable "value" { type = integer }`,
+			wantErr:   "invalid type of variable 'value'",
+		},
+		{
+			name: "passing validation",
+			variables: `
+variable "value" {
+  type = number
+  validation {
+    condition     = var.value > 0
+    error_message = "value must be positive"
+  }
+}`,
+			vars: Vars{"value": "3"},
+			want: cty.NumberIntVal(3),
+		},
+		{
+			name: "failing validation",
+			variables: `
+variable "value" {
+  type    = number
+  default = 0
+  validation {
+    condition     = var.value > 0
+    error_message = "value must be positive"
+  }
+}`,
+			wantErr: "invalid value for variable 'value': value must be positive",
+		},
+		{
+			name: "validation using another variable",
+			variables: `
+variable "value" {
+  default = "prod"
+  validation {
+    condition     = var.value == var.envs[0]
+    error_message = "unknown env"
+  }
+}
+
+variable "envs" {
+  type    = list(string)
+  default = ["dev"]
+}`,
+			wantErr: "invalid value for variable 'value': unknown env",
+		},
+		{
+			name: "non-boolean condition",
+			variables: `
+variable "value" {
+  default = "a"
+  validation {
+    condition     = var.value
+    error_message = "invalid"
+  }
+}`,
+			wantErr: "validation condition of variable 'value' must be a boolean",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := loadTestConfig(variableConfig(tt.variables), tt.vars)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadConfigFS() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfigFS() error = %v", err)
+			}
+			var got cty.Value
+			for _, v := range conf.Variables {
+				if v.Name == "value" {
+					got = v.Value
+				}
+			}
+			if got == cty.NilVal || !got.RawEquals(tt.want) {
+				t.Errorf("value = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSensitiveVariables(t *testing.T) {
+	src := `
+variable "password" {
+  sensitive = true
+}
+
+variable "hosts" {
+  type      = list(string)
+  sensitive = true
+}
+
+variable "user" {
+  default = "app_user"
+}
+
+variable "schema" {
+  default   = "p"
+  sensitive = true
+}
+
+env "dev" {
+  db_url       = "postgres://${var.user}:${var.password}@${var.hosts[0]}/dev"
+  schema_files = ["schema.sql"]
+}
+`
+	vars := Vars{"password": "s3cr3t/p@ss", "hosts": `["db-7f3a.internal", "db-91c2.internal"]`}
+	conf, err := loadTestConfig(src, vars)
+	if err != nil {
+		t.Fatalf("LoadConfigFS() error = %v", err)
+	}
+	dbURL := conf.Envs[0].DBUrl
+	if want := "postgres://app_user:s3cr3t/p@ss@db-7f3a.internal/dev"; dbURL != want {
+		t.Fatalf("db_url = %q, want %q", dbURL, want)
+	}
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{dbURL, "postgres://app_user:(sensitive value)@(sensitive value)/dev"},
+		{"query s3cr3t%2Fp%40ss", "query (sensitive value)"},
+		{"path s3cr3t%2Fp@ss", "path (sensitive value)"},
+		{"dial tcp db-91c2.internal:5432: refused", "dial tcp (sensitive value):5432: refused"},
+		{"user app_user", "user app_user"},
+		// Values too short to be told apart from unrelated output are left alone.
+		{"Repeatable p", "Repeatable p"},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.in); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestVarsSet(t *testing.T) {
+	var vars Vars
+	for _, arg := range []string{"a=1", " b =x=y", "c="} {
+		if err := vars.Set(arg); err != nil {
+			t.Fatalf("Set(%q) error = %v", arg, err)
+		}
+	}
+	if want := (Vars{"a": "1", "b": "x=y", "c": ""}); !maps.Equal(vars, want) {
+		t.Errorf("vars = %v, want %v", vars, want)
+	}
+	for _, arg := range []string{"a", "=1"} {
+		if err := vars.Set(arg); err == nil {
+			t.Errorf("Set(%q) succeeded", arg)
+		}
+	}
+}
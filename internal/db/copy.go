@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Table is a table whose rows can be copied, with its columns in order. Generated columns are left out,
+// as they cannot be written.
+type Table struct {
+	Schema  string
+	Name    string
+	Columns []string
+}
+
+func (t Table) String() string {
+	return t.Schema + "." + t.Name
+}
+
+// ListTables returns the tables of the given schemas, sorted by schema and name. Partitions are left
+// out, as their rows are copied through their partitioned table.
+func (c *Conn) ListTables(ctx context.Context, schemas []string) ([]Table, error) {
+	rows, err := c.QueryContext(ctx, `
+		SELECT n.nspname, c.relname, a.attname
+		FROM pg_catalog.pg_class c
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_catalog.pg_attribute a ON a.attrelid = c.oid
+		WHERE n.nspname = ANY($1)
+			AND ((c.relkind = 'r' AND NOT c.relispartition) OR c.relkind = 'p')
+			AND a.attnum > 0 AND NOT a.attisdropped AND a.attgenerated = ''
+		ORDER BY n.nspname, c.relname, a.attnum;`, schemas)
+	if err != nil {
+		return nil, fmt.Errorf("listing tables: %w", err)
+	}
+	defer rows.Close()
+	var tables []Table
+	for rows.Next() {
+		var schema, name, column string
+		if err := rows.Scan(&schema, &name, &column); err != nil {
+			return nil, err
+		}
+		if n := len(tables); n == 0 || tables[n-1].Schema != schema || tables[n-1].Name != name {
+			tables = append(tables, Table{Schema: schema, Name: name})
+		}
+		tables[len(tables)-1].Columns = append(tables[len(tables)-1].Columns, column)
+	}
+	return tables, rows.Err()
+}
+
+// CopyRows copies up to limit rows of a table from src to the table of the same name in dst, and
+// returns the number of rows copied. Triggers and foreign keys are not enforced on dst when the role
+// is allowed to disable them, as the copied rows may reference rows that were not copied.
+func CopyRows(ctx context.Context, src, dst *sql.DB, table Table, limit int) (int64, error) {
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer srcConn.Close()
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer dstConn.Close()
+
+	if _, err := dstConn.ExecContext(ctx, "SET session_replication_role = replica"); err == nil {
+		defer dstConn.ExecContext(ctx, "RESET session_replication_role")
+	}
+
+	ident := pgx.Identifier{table.Schema, table.Name}.Sanitize()
+	columns := make([]string, 0, len(table.Columns))
+	for _, column := range table.Columns {
+		columns = append(columns, pgx.Identifier{column}.Sanitize())
+	}
+	copyOut := fmt.Sprintf("COPY (SELECT %s FROM %s LIMIT %d) TO STDOUT", strings.Join(columns, ", "), ident, limit)
+	copyIn := fmt.Sprintf("COPY %s (%s) FROM STDIN", ident, strings.Join(columns, ", "))
+
+	r, w := io.Pipe()
+	copied := make(chan error, 1)
+	go func() {
+		err := srcConn.Raw(func(driverConn any) error {
+			_, err := driverConn.(*stdlib.Conn).Conn().PgConn().CopyTo(ctx, w, copyOut)
+			return err
+		})
+		w.CloseWithError(err)
+		copied <- err
+	}()
+	var rows int64
+	err = dstConn.Raw(func(driverConn any) error {
+		tag, err := driverConn.(*stdlib.Conn).Conn().PgConn().CopyFrom(ctx, r, copyIn)
+		rows = tag.RowsAffected()
+		return err
+	})
+	// Unblock the copy out of src if the copy into dst failed, in which case its error is only a consequence.
+	r.CloseWithError(err)
+	if srcErr := <-copied; err == nil {
+		err = srcErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("copying rows of %s: %w", table, err)
+	}
+	return rows, nil
+}
@@ -2,11 +2,17 @@ package db
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"strings"
 	"time"
 
+	"github.com/cortea-ai/pg-migrant/internal/sqlparse"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/stdlib"
@@ -14,11 +20,42 @@ import (
 
 const PGMigrantSchema = "pgmigrant"
 const MigrationTableName = PGMigrantSchema + ".current_version"
+const HistoryTableName = PGMigrantSchema + ".history"
+
+// Statuses recorded in the history table.
+const (
+	StatusApplied  = "applied"
+	StatusFailed   = "failed"
+	StatusBaseline = "baseline"
+)
 
 var ErrTableNotFound = errors.New("table not found")
 
 type Conn struct {
 	*sql.DB
+	// Logger receives the statements of the migrations applied. Nothing is logged by default.
+	Logger *slog.Logger
+	// StatementHook, if set, wraps the execution of each statement of the migrations applied by
+	// ApplyMigration and ApplyRepeatable.
+	StatementHook StatementHook
+}
+
+// StatementHook wraps the execution of a statement of a migration, e.g. to time it. version is the
+// name of the migration for repeatable migrations. statement is the 1-based index of the statement in
+// the migration. exec runs the statement and must be called once.
+type StatementHook func(ctx context.Context, version string, statement int, sql string, exec func(ctx context.Context) error) error
+
+// execStatement runs a statement of a migration through the statement hook.
+func (c *Conn) execStatement(ctx context.Context, e execer, version string, statement int, sql string) error {
+	c.Logger.Debug("executing statement", "version", version, "statement", statement)
+	exec := func(ctx context.Context) error {
+		_, err := e.ExecContext(ctx, sql)
+		return err
+	}
+	if c.StatementHook == nil {
+		return exec(ctx)
+	}
+	return c.StatementHook(ctx, version, statement, sql, exec)
 }
 
 func NewConnEnsureVersionTable(ctx context.Context, url string) (*Conn, string, error) {
@@ -36,16 +73,35 @@ func NewConnEnsureVersionTable(ctx context.Context, url string) (*Conn, string,
 			return nil, "", err
 		}
 	}
+	if err := conn.CreateHistoryTable(ctx); err != nil {
+		return nil, "", err
+	}
+	if err := conn.CreateRepeatableTable(ctx); err != nil {
+		return nil, "", err
+	}
 	return conn, currentVersion, nil
 }
 
 func NewConn(ctx context.Context, url string) (*Conn, error) {
 	connConfig, err := pgx.ParseConfig(url)
 	if err != nil {
-		return nil, err
+		return nil, SanitizeParseConfigError(err)
 	}
 	conn := stdlib.OpenDB(*connConfig)
-	return &Conn{conn}, nil
+	return &Conn{DB: conn, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}, nil
+}
+
+// SanitizeParseConfigError strips the connection string from a pgx.ParseConfig error. pgx masks the
+// password, but the masking is defeated by passwords containing characters such as '@'.
+func SanitizeParseConfigError(err error) error {
+	cause := err
+	for errors.Unwrap(cause) != nil {
+		cause = errors.Unwrap(cause)
+	}
+	if cause == err {
+		return errors.New("invalid connection string")
+	}
+	return fmt.Errorf("invalid connection string: %w", cause)
 }
 
 func (c *Conn) Close(ctx context.Context) error {
@@ -54,8 +110,7 @@ func (c *Conn) Close(ctx context.Context) error {
 
 func (c *Conn) CreateMigrationTable(ctx context.Context) error {
 	if _, err := c.ExecContext(ctx, `CREATE SCHEMA IF NOT EXISTS `+PGMigrantSchema+`;`); err != nil {
-		fmt.Printf("error creating schema: %v", err)
-		return err
+		return fmt.Errorf("creating schema: %w", err)
 	}
 	if _, err := c.ExecContext(ctx, `
 		CREATE TABLE `+MigrationTableName+` (
@@ -69,6 +124,23 @@ func (c *Conn) CreateMigrationTable(ctx context.Context) error {
 	return nil
 }
 
+// CreateHistoryTable creates the table recording every migration version
+// known to the database. It is a no-op if the table already exists.
+func (c *Conn) CreateHistoryTable(ctx context.Context) error {
+	if _, err := c.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS `+HistoryTableName+` (
+			version text PRIMARY KEY,
+			checksum text NOT NULL,
+			status text NOT NULL,
+			note text NOT NULL DEFAULT '',
+			applied_at timestamptz NOT NULL DEFAULT now()
+		);
+	`); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (c *Conn) CheckCurrentVersion(ctx context.Context) (string, error) {
 	var version string
 	err := c.QueryRowContext(ctx, `SELECT version FROM `+MigrationTableName).Scan(&version)
@@ -91,52 +163,222 @@ var (
 	defaultLockTimeout = 60 * time.Second
 )
 
+// Checksum returns the checksum recorded in the history table for a migration.
+func Checksum(sql string) string {
+	sum := sha256.Sum256([]byte(sql))
+	return hex.EncodeToString(sum[:])
+}
+
+// HasHistory reports whether the history table has an entry for version. A database without the history
+// table, created by an older pg-migrant, has none.
+func (c *Conn) HasHistory(ctx context.Context, version string) (bool, error) {
+	var exists bool
+	err := c.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM `+HistoryTableName+` WHERE version = $1)`, version).Scan(&exists)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "42P01" {
+		return false, nil
+	}
+	return exists, err
+}
+
+// MarkBaselineSatisfied records a baseline migration as applied without running it. Databases that are
+// already past the baseline version contain everything it would create.
+func (c *Conn) MarkBaselineSatisfied(ctx context.Context, version, sql string) error {
+	return upsertHistory(ctx, c.DB, version, Checksum(sql), StatusBaseline, "satisfied by migrations applied before the baseline")
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func upsertHistory(ctx context.Context, e execer, version, checksum, status, note string) error {
+	if _, err := e.ExecContext(ctx, `
+		INSERT INTO `+HistoryTableName+` (version, checksum, status, note) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (version) DO UPDATE SET checksum = $2, status = $3, note = $4, applied_at = now();`,
+		version, checksum, status, note); err != nil {
+		return fmt.Errorf("failed to record migration %s in history: %w", version, err)
+	}
+	return nil
+}
+
+// NoTransactionMarker, on a line of its own, marks a migration whose statements cannot run inside a
+// transaction, such as CREATE INDEX CONCURRENTLY.
+const NoTransactionMarker = "-- pg-migrant: transaction=none"
+
+// IsNonTransactional reports whether a migration carries the NoTransactionMarker.
+func IsNonTransactional(migration string) bool {
+	for _, line := range strings.Split(migration, "\n") {
+		if strings.TrimSpace(line) == NoTransactionMarker {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Conn) ApplyMigration(ctx context.Context, version, sql string) error {
-	start := time.Now()
+	if err := c.applyMigration(ctx, version, sql); err != nil {
+		if histErr := upsertHistory(ctx, c.DB, version, Checksum(sql), StatusFailed, err.Error()); histErr != nil {
+			return errors.Join(err, histErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *Conn) applyMigration(ctx context.Context, version, sql string) error {
+	if IsNonTransactional(sql) {
+		return c.applyNonTransactional(ctx, version, sql)
+	}
 	tx, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
 	defer tx.Rollback() // No-op if committed successfully
+	if err := setSessionTimeouts(ctx, tx); err != nil {
+		return err
+	}
+	for i, stmt := range sqlparse.Split(sql) {
+		if err := c.execStatement(ctx, tx, version, i+1, stmt.SQL); err != nil {
+			return fmt.Errorf("failed to execute statement %d (line %d) of migration: %w", i+1, stmt.Line, err)
+		}
+	}
+	if err := recordApplied(ctx, tx, version, sql); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// applyNonTransactional runs the statements of a migration one by one, outside of a transaction, then
+// records the migration as applied. Statements that succeeded before a failure are not rolled back.
+func (c *Conn) applyNonTransactional(ctx context.Context, version, sql string) error {
+	// Use a single connection so that the session timeouts apply to every statement.
+	conn, err := c.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring connection: %w", err)
+	}
+	defer conn.Close()
+	if err := setSessionTimeouts(ctx, conn); err != nil {
+		return err
+	}
+	for i, stmt := range sqlparse.Split(sql) {
+		if err := c.execStatement(ctx, conn, version, i+1, stmt.SQL); err != nil {
+			return fmt.Errorf("failed to execute statement %d (line %d) of migration: %w", i+1, stmt.Line, err)
+		}
+	}
+	tx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("beginning transaction: %w", err)
 	}
-	// Due to the way *sql.Db works, when a statement_timeout is set for the session, it will NOT reset
-	// by default when it's returned to the pool.
-	//
-	// We can't set the timeout at the TRANSACTION-level (for each transaction) because `ADD INDEX CONCURRENTLY`
-	// must be executed within its own transaction block. Postgres will error if you try to set a TRANSACTION-level
-	// timeout for it. SESSION-level statement_timeouts are respected by `ADD INDEX CONCURRENTLY`
-	if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET SESSION statement_timeout = %d", defaultTimeout.Milliseconds())); err != nil {
+	defer tx.Rollback() // No-op if committed successfully
+	if err := recordApplied(ctx, tx, version, sql); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// setSessionTimeouts sets the statement and lock timeouts of migrations.
+//
+// Due to the way *sql.Db works, when a statement_timeout is set for the session, it will NOT reset
+// by default when it's returned to the pool.
+//
+// We can't set the timeout at the TRANSACTION-level (for each transaction) because `ADD INDEX CONCURRENTLY`
+// must be executed within its own transaction block. Postgres will error if you try to set a TRANSACTION-level
+// timeout for it. SESSION-level statement_timeouts are respected by `ADD INDEX CONCURRENTLY`
+func setSessionTimeouts(ctx context.Context, e execer) error {
+	if _, err := e.ExecContext(ctx, fmt.Sprintf("SET SESSION statement_timeout = %d", defaultTimeout.Milliseconds())); err != nil {
 		return fmt.Errorf("setting statement timeout: %w", err)
 	}
-	if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET SESSION lock_timeout = %d", defaultLockTimeout.Milliseconds())); err != nil {
+	if _, err := e.ExecContext(ctx, fmt.Sprintf("SET SESSION lock_timeout = %d", defaultLockTimeout.Milliseconds())); err != nil {
 		return fmt.Errorf("setting lock timeout: %w", err)
 	}
-	if _, err := tx.ExecContext(ctx, sql); err != nil {
-		return fmt.Errorf("failed to execute migration: %w", err)
+	return nil
+}
+
+// DryRunLockTimeout is the lock timeout of dry runs. It is short so that a dry run against a live
+// database fails rather than queueing behind its traffic, and blocking it in turn.
+const DryRunLockTimeout = 2 * time.Second
+
+// DryRun runs fn in a transaction that is always rolled back, with the statement timeout of migrations
+// and DryRunLockTimeout as lock timeout. The transaction is a pgx.Tx so that Go migrations can run in it.
+func (c *Conn) DryRun(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	conn, err := c.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring connection: %w", err)
 	}
-	if _, err = tx.ExecContext(ctx, `
+	defer conn.Close()
+	return conn.Raw(func(driverConn any) error {
+		tx, err := driverConn.(*stdlib.Conn).Conn().Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("beginning transaction: %w", err)
+		}
+		defer tx.Rollback(ctx) // Always rolled back
+		if _, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", defaultTimeout.Milliseconds())); err != nil {
+			return fmt.Errorf("setting statement timeout: %w", err)
+		}
+		if _, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL lock_timeout = %d", DryRunLockTimeout.Milliseconds())); err != nil {
+			return fmt.Errorf("setting lock timeout: %w", err)
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if err := tx.Rollback(ctx); err != nil {
+			return fmt.Errorf("rolling back transaction: %w", err)
+		}
+		return nil
+	})
+}
+
+// recordApplied moves the current version to version and records the migration as applied.
+func recordApplied(ctx context.Context, e execer, version, sql string) error {
+	if _, err := e.ExecContext(ctx, `
 		INSERT INTO `+MigrationTableName+` (id, version) VALUES (1, $1)
 		ON CONFLICT (id) DO UPDATE SET version = $1;`, version); err != nil {
 		return fmt.Errorf("failed to update current version: %w", err)
 	}
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("committing transaction: %w", err)
-	}
-	fmt.Printf("\n✅ Finished executing statement. Duration: %s\n", time.Since(start))
-	return nil
+	return upsertHistory(ctx, e, version, Checksum(sql), StatusApplied, "")
 }
 
-func (c *Conn) CleanSchema(ctx context.Context) error {
+// CleanSchemas drops pg-migrant's schema and the given schemas, then recreates the given schemas
+// empty.
+func (c *Conn) CleanSchemas(ctx context.Context, schemas []string) error {
 	if _, err := c.ExecContext(ctx, `DROP SCHEMA IF EXISTS `+PGMigrantSchema+` CASCADE;`); err != nil {
-		fmt.Printf("error cleaning pg-migrant schema: %v", err)
-		return err
-	}
-	if _, err := c.ExecContext(ctx, `DROP SCHEMA IF EXISTS public CASCADE;`); err != nil {
-		fmt.Printf("error cleaning public schema: %v", err)
-		return err
+		return fmt.Errorf("cleaning pg-migrant schema: %w", err)
 	}
-	if _, err := c.ExecContext(ctx, `CREATE SCHEMA public;`); err != nil {
-		fmt.Printf("error creating public schema: %v", err)
-		return err
+	for _, schema := range schemas {
+		name := pgx.Identifier{schema}.Sanitize()
+		if _, err := c.ExecContext(ctx, `DROP SCHEMA IF EXISTS `+name+` CASCADE;`); err != nil {
+			return fmt.Errorf("cleaning %s schema: %w", schema, err)
+		}
+		if _, err := c.ExecContext(ctx, `CREATE SCHEMA `+name+`;`); err != nil {
+			return fmt.Errorf("creating %s schema: %w", schema, err)
+		}
 	}
 	return nil
 }
+
+// ListSchemas returns the names of the schemas of the database, excluding system schemas, sorted.
+func (c *Conn) ListSchemas(ctx context.Context) ([]string, error) {
+	rows, err := c.QueryContext(ctx, `
+		SELECT nspname FROM pg_catalog.pg_namespace
+		WHERE nspname !~ '^pg_' AND nspname <> 'information_schema'
+		ORDER BY nspname;`)
+	if err != nil {
+		return nil, fmt.Errorf("listing schemas: %w", err)
+	}
+	defer rows.Close()
+	var schemas []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, name)
+	}
+	return schemas, rows.Err()
+}
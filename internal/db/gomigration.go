@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// ApplyGoMigration runs a migration written in Go and records it as applied, in the same transaction.
+// description stands for the content of the migration in the history table.
+func (c *Conn) ApplyGoMigration(ctx context.Context, version, description string, fn func(context.Context, pgx.Tx) error) error {
+	if err := c.applyGoMigration(ctx, version, description, fn); err != nil {
+		if histErr := upsertHistory(ctx, c.DB, version, Checksum(description), StatusFailed, err.Error()); histErr != nil {
+			return errors.Join(err, histErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *Conn) applyGoMigration(ctx context.Context, version, description string, fn func(context.Context, pgx.Tx) error) error {
+	conn, err := c.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring connection: %w", err)
+	}
+	defer conn.Close()
+	return InPgxTx(ctx, conn, func(tx pgx.Tx) error {
+		if err := setSessionTimeouts(ctx, pgxExecer{tx}); err != nil {
+			return err
+		}
+		if err := fn(ctx, tx); err != nil {
+			return fmt.Errorf("failed to execute migration: %w", err)
+		}
+		return recordApplied(ctx, pgxExecer{tx}, version, description)
+	})
+}
+
+// InPgxTx runs fn in a pgx transaction on conn, which is committed if fn succeeds. Go migrations are
+// given a pgx.Tx rather than a *sql.Tx.
+func InPgxTx(ctx context.Context, conn *sql.Conn, fn func(tx pgx.Tx) error) error {
+	return conn.Raw(func(driverConn any) error {
+		tx, err := driverConn.(*stdlib.Conn).Conn().Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("beginning transaction: %w", err)
+		}
+		defer tx.Rollback(ctx) // No-op if committed successfully
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("committing transaction: %w", err)
+		}
+		return nil
+	})
+}
+
+// pgxExecer adapts a pgx.Tx to the execer interface.
+type pgxExecer struct {
+	tx pgx.Tx
+}
+
+func (e pgxExecer) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	tag, err := e.tx.Exec(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(tag.RowsAffected()), nil
+}
@@ -0,0 +1,168 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const AuditTableName = PGMigrantSchema + ".audit_log"
+
+// StatusMarked is recorded for migrations marked as applied without being executed.
+const StatusMarked = "marked"
+
+// HistoryEntry is a row of the history table.
+type HistoryEntry struct {
+	Version  string
+	Checksum string
+	Status   string
+	Note     string
+}
+
+// RepairResult lists the history entries changed by Repair.
+type RepairResult struct {
+	Updated []string
+	Removed []string
+}
+
+// CreateAuditTable creates the table recording manual changes to the migration history.
+// It is a no-op if the table already exists.
+func (c *Conn) CreateAuditTable(ctx context.Context) error {
+	if _, err := c.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS `+AuditTableName+` (
+			id bigserial PRIMARY KEY,
+			action text NOT NULL,
+			version text NOT NULL DEFAULT '',
+			note text NOT NULL DEFAULT '',
+			performed_by text NOT NULL DEFAULT current_user,
+			performed_at timestamptz NOT NULL DEFAULT now()
+		);
+	`); err != nil {
+		return err
+	}
+	return nil
+}
+
+// History returns every entry of the history table, ordered by version. A database without the history
+// table, created by an older pg-migrant, has none.
+func (c *Conn) History(ctx context.Context) ([]HistoryEntry, error) {
+	rows, err := c.QueryContext(ctx, `SELECT version, checksum, status, note FROM `+HistoryTableName+` ORDER BY version`)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "42P01" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []HistoryEntry
+	for rows.Next() {
+		var e HistoryEntry
+		if err := rows.Scan(&e.Version, &e.Checksum, &e.Status, &e.Note); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// MarkApplied records a migration as applied without executing it. The current version only moves
+// forward: marking an older version leaves it unchanged. Callers make sure no earlier migration is
+// pending, as apply would skip it.
+func (c *Conn) MarkApplied(ctx context.Context, version, migration, note string) error {
+	return c.inAuditedTx(ctx, "mark-applied", version, note, func(tx *sql.Tx) error {
+		if err := upsertHistory(ctx, tx, version, Checksum(migration), StatusMarked, note); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO `+MigrationTableName+` (id, version) VALUES (1, $1)
+			ON CONFLICT (id) DO UPDATE SET version = $1
+			WHERE `+MigrationTableName+`.version < $1;`, version); err != nil {
+			return fmt.Errorf("failed to update current version: %w", err)
+		}
+		return nil
+	})
+}
+
+// Unmark removes a migration from the history. If it is the current version, the current version is
+// moved back to the latest earlier migration of the history that did not fail, or cleared if there is
+// none.
+func (c *Conn) Unmark(ctx context.Context, version, note string) error {
+	return c.inAuditedTx(ctx, "unmark", version, note, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+HistoryTableName+` WHERE version = $1`, version); err != nil {
+			return fmt.Errorf("failed to remove migration %s from history: %w", version, err)
+		}
+		var previousVersion sql.NullString
+		if err := tx.QueryRowContext(ctx, `SELECT max(version) FROM `+HistoryTableName+` WHERE version < $1 AND status <> $2`,
+			version, StatusFailed).Scan(&previousVersion); err != nil {
+			return fmt.Errorf("failed to find the migration before %s in history: %w", version, err)
+		}
+		var err error
+		if !previousVersion.Valid {
+			_, err = tx.ExecContext(ctx, `DELETE FROM `+MigrationTableName+` WHERE version = $1`, version)
+		} else {
+			_, err = tx.ExecContext(ctx, `UPDATE `+MigrationTableName+` SET version = $2 WHERE version = $1`, version, previousVersion.String)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update current version: %w", err)
+		}
+		return nil
+	})
+}
+
+// Repair removes failed entries from the history and updates the checksum of every other entry found
+// in checksums, keyed by version.
+func (c *Conn) Repair(ctx context.Context, checksums map[string]string, note string) (RepairResult, error) {
+	var result RepairResult
+	entries, err := c.History(ctx)
+	if err != nil {
+		return result, err
+	}
+	err = c.inAuditedTx(ctx, "repair", "", note, func(tx *sql.Tx) error {
+		for _, e := range entries {
+			if e.Status == StatusFailed {
+				if _, err := tx.ExecContext(ctx, `DELETE FROM `+HistoryTableName+` WHERE version = $1`, e.Version); err != nil {
+					return fmt.Errorf("failed to remove failed migration %s: %w", e.Version, err)
+				}
+				result.Removed = append(result.Removed, e.Version)
+				continue
+			}
+			checksum, ok := checksums[e.Version]
+			if !ok || checksum == e.Checksum {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, `UPDATE `+HistoryTableName+` SET checksum = $2 WHERE version = $1`, e.Version, checksum); err != nil {
+				return fmt.Errorf("failed to update checksum of migration %s: %w", e.Version, err)
+			}
+			result.Updated = append(result.Updated, e.Version)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// inAuditedTx runs fn in a transaction that also records action in the audit log.
+func (c *Conn) inAuditedTx(ctx context.Context, action, version, note string, fn func(tx *sql.Tx) error) error {
+	if err := c.CreateAuditTable(ctx); err != nil {
+		return err
+	}
+	tx, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback() // No-op if committed successfully
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO `+AuditTableName+` (action, version, note) VALUES ($1, $2, $3)`,
+		action, version, note); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// lockKey is the key of the advisory lock held while migrations are applied. It spells "pgmigrant" in
+// ASCII, truncated to 64 bits.
+const lockKey int64 = 0x70676d6967726e74
+
+// Lock acquires the advisory lock that serializes pg-migrant runs against the database, waiting for the
+// run holding it, if any, to finish, in which case wait is called first. The returned function releases
+// the lock.
+func (c *Conn) Lock(ctx context.Context, wait func()) (func(), error) {
+	conn, err := c.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquiring connection: %w", err)
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, lockKey).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("acquiring migration lock: %w", err)
+	}
+	if !acquired {
+		wait()
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("acquiring migration lock: %w", err)
+		}
+	}
+	return func() { unlock(conn) }, nil
+}
+
+func unlock(conn *sql.Conn) {
+	// The lock is released with the session anyway, should the unlock fail.
+	conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+	conn.Close()
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cortea-ai/pg-migrant/internal/sqlparse"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const RepeatableTableName = PGMigrantSchema + ".repeatable"
+
+// CreateRepeatableTable creates the table recording the checksum of every repeatable migration applied.
+// It is a no-op if the table already exists.
+func (c *Conn) CreateRepeatableTable(ctx context.Context) error {
+	if _, err := c.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS `+RepeatableTableName+` (
+			name text PRIMARY KEY,
+			checksum text NOT NULL,
+			applied_at timestamptz NOT NULL DEFAULT now()
+		);
+	`); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RepeatableChecksums returns the checksums of the repeatable migrations last applied, keyed by name.
+// It is empty if none was ever applied.
+func (c *Conn) RepeatableChecksums(ctx context.Context) (map[string]string, error) {
+	checksums := make(map[string]string)
+	rows, err := c.QueryContext(ctx, `SELECT name, checksum FROM `+RepeatableTableName)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "42P01" {
+			return checksums, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, checksum string
+		if err := rows.Scan(&name, &checksum); err != nil {
+			return nil, err
+		}
+		checksums[name] = checksum
+	}
+	return checksums, rows.Err()
+}
+
+// ApplyRepeatable runs a repeatable migration in a transaction and records its checksum. Its statements
+// go through the statement hook with the name of the migration as their version.
+func (c *Conn) ApplyRepeatable(ctx context.Context, name, sql string) error {
+	tx, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback() // No-op if committed successfully
+	if err := setSessionTimeouts(ctx, tx); err != nil {
+		return err
+	}
+	for i, stmt := range sqlparse.Split(sql) {
+		if err := c.execStatement(ctx, tx, name, i+1, stmt.SQL); err != nil {
+			return fmt.Errorf("failed to execute statement %d (line %d) of repeatable migration %s: %w", i+1, stmt.Line, name, err)
+		}
+	}
+	if err := recordRepeatable(ctx, tx, name, sql); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+func recordRepeatable(ctx context.Context, e execer, name, sql string) error {
+	if _, err := e.ExecContext(ctx, `
+		INSERT INTO `+RepeatableTableName+` (name, checksum) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET checksum = $2, applied_at = now();`,
+		name, Checksum(sql)); err != nil {
+		return fmt.Errorf("failed to record repeatable migration %s: %w", name, err)
+	}
+	return nil
+}
@@ -2,32 +2,99 @@ package diffutils
 
 import (
 	"fmt"
-	"os"
+	"io/fs"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/cortea-ai/pg-migrant/internal/db"
+	"github.com/cortea-ai/pg-migrant/internal/sqlparse"
 	"github.com/stripe/pg-schema-diff/pkg/diff"
 )
 
-func GetDDLsFromFiles(filePaths []string) ([]string, error) {
-	var ddls []string
+// DDL is a single statement of a schema file.
+type DDL struct {
+	SQL  string
+	File string
+	// Line is the 1-based line of the file the statement starts on.
+	Line int
+}
+
+// Location returns the file and line of the statement, e.g. schema/users.sql:12.
+func (d DDL) Location() string {
+	return fmt.Sprintf("%s:%d", d.File, d.Line)
+}
+
+// GetDDLsFromFiles reads the files from fsys and splits them into individual statements, in order.
+func GetDDLsFromFiles(fsys fs.FS, filePaths []string) ([]DDL, error) {
+	var ddls []DDL
 	for _, path := range filePaths {
 		if strings.ToLower(filepath.Ext(path)) != ".sql" {
 			return nil, fmt.Errorf("file %q is not a .sql file", path)
 		}
-		fileContents, err := os.ReadFile(path)
+		fileContents, err := fs.ReadFile(fsys, path)
 		if err != nil {
 			return nil, fmt.Errorf("reading file %q: %w", path, err)
 		}
-		// In the future, it would make sense to split the file contents into individual DDL statements; however,
-		// that would require fully parsing the SQL. Naively splitting on `;` would not work because `;` can be
-		// used in comments, strings, and escaped identifiers.
-		ddls = append(ddls, string(fileContents))
+		for _, stmt := range sqlparse.Split(string(fileContents)) {
+			ddls = append(ddls, DDL{SQL: stmt.SQL, File: path, Line: stmt.Line})
+		}
 	}
 	return ddls, nil
 }
 
+// PlanToPrettyS renders a plan as a migration running in a single transaction: concurrent index
+// statements are rewritten to their blocking form.
 func PlanToPrettyS(plan diff.Plan) string {
+	return planToPrettyS(StripConcurrently(plan))
+}
+
+// PlanToNonTransactionalS renders a plan as a migration carrying the no-transaction marker, so that its
+// concurrent index statements are kept and its statements run one by one. Each statement is preceded
+// by its session timeouts, as concurrent index builds may take much longer than other statements.
+func PlanToNonTransactionalS(plan diff.Plan) string {
+	var stmts []diff.Statement
+	for _, stmt := range plan.Statements {
+		if stmt.Timeout > 0 {
+			stmts = append(stmts, diff.Statement{DDL: fmt.Sprintf("SET SESSION statement_timeout = %d", stmt.Timeout.Milliseconds())})
+		}
+		if stmt.LockTimeout > 0 {
+			stmts = append(stmts, diff.Statement{DDL: fmt.Sprintf("SET SESSION lock_timeout = %d", stmt.LockTimeout.Milliseconds())})
+		}
+		stmts = append(stmts, stmt)
+	}
+	plan.Statements = stmts
+	return db.NoTransactionMarker + "\n\n" + planToPrettyS(plan)
+}
+
+// SplitPlan splits a plan into consecutive plans that can each run as a migration: every concurrent
+// index statement and every constraint validation gets a plan of its own, and the statements between
+// them are kept together.
+func SplitPlan(plan diff.Plan) []diff.Plan {
+	var plans []diff.Plan
+	var current []diff.Statement
+	flush := func() {
+		if len(current) > 0 {
+			part := plan
+			part.Statements = current
+			plans = append(plans, part)
+			current = nil
+		}
+	}
+	for _, stmt := range plan.Statements {
+		if IsConcurrent(stmt) || IsValidation(stmt) {
+			flush()
+			current = []diff.Statement{stmt}
+			flush()
+			continue
+		}
+		current = append(current, stmt)
+	}
+	flush()
+	return plans
+}
+
+func planToPrettyS(plan diff.Plan) string {
 	sb := strings.Builder{}
 
 	if len(plan.Statements) == 0 {
@@ -37,7 +104,6 @@ func PlanToPrettyS(plan diff.Plan) string {
 
 	var stmtStrs []string
 	for _, stmt := range plan.Statements {
-		stmt = adaptStatement(stmt)
 		stmtStr := statementToPrettyS(stmt)
 		stmtStrs = append(stmtStrs, stmtStr)
 	}
@@ -47,6 +113,81 @@ func PlanToPrettyS(plan diff.Plan) string {
 	return sb.String()
 }
 
+// StatementToSQL renders a statement as plain DDL for a declarative schema file, without its hazards.
+func StatementToSQL(stmt diff.Statement) string {
+	return adaptStatement(stmt).DDL + ";"
+}
+
+// StripConcurrently rewrites the concurrent index statements of a plan to their blocking form, so that
+// the plan can run in a single transaction.
+func StripConcurrently(plan diff.Plan) diff.Plan {
+	stmts := make([]diff.Statement, 0, len(plan.Statements))
+	for _, stmt := range plan.Statements {
+		stmts = append(stmts, adaptStatement(stmt))
+	}
+	plan.Statements = stmts
+	return plan
+}
+
+// IsConcurrent reports whether a statement cannot run inside a transaction.
+func IsConcurrent(stmt diff.Statement) bool {
+	return sqlparse.HasKeywords(stmt.DDL, "CONCURRENTLY")
+}
+
+// IsValidation reports whether a statement validates a constraint added as NOT VALID.
+func IsValidation(stmt diff.Statement) bool {
+	return sqlparse.HasKeywords(stmt.DDL, "VALIDATE", "CONSTRAINT")
+}
+
+// IgnoreHazards removes the hazards of the given types from the statements of a plan.
+func IgnoreHazards(plan diff.Plan, types []string) diff.Plan {
+	stmts := make([]diff.Statement, 0, len(plan.Statements))
+	for _, stmt := range plan.Statements {
+		var hazards []diff.MigrationHazard
+		for _, hazard := range stmt.Hazards {
+			if !slices.Contains(types, hazard.Type) {
+				hazards = append(hazards, hazard)
+			}
+		}
+		stmt.Hazards = hazards
+		stmts = append(stmts, stmt)
+	}
+	plan.Statements = stmts
+	return plan
+}
+
+// NotValidForeignKeys splits the foreign keys a plan adds with a SHARE ROW EXCLUSIVE lock on existing
+// tables into a NOT VALID constraint and its validation. The validations follow the other statements,
+// and must run in migrations of their own, see SplitPlan: in the transaction adding the constraint, they
+// would hold its lock, whereas on their own they take a SHARE UPDATE EXCLUSIVE lock, which does not block
+// writes.
+func NotValidForeignKeys(plan diff.Plan) diff.Plan {
+	var stmts, validations []diff.Statement
+	for _, stmt := range plan.Statements {
+		locks := slices.ContainsFunc(stmt.Hazards, func(h diff.MigrationHazard) bool {
+			return h.Type == diff.MigrationHazardTypeAcquiresShareRowExclusiveLock
+		})
+		table, constraint, ok := sqlparse.AddedConstraint(stmt.DDL)
+		if !locks || !ok || !sqlparse.HasKeywords(stmt.DDL, "FOREIGN", "KEY") || sqlparse.HasKeywords(stmt.DDL, "NOT", "VALID") {
+			stmts = append(stmts, stmt)
+			continue
+		}
+		notValid := stmt
+		notValid.DDL = stmt.DDL + " NOT VALID"
+		notValid.Hazards = slices.DeleteFunc(slices.Clone(stmt.Hazards), func(h diff.MigrationHazard) bool {
+			return h.Type == diff.MigrationHazardTypeAcquiresShareRowExclusiveLock
+		})
+		stmts = append(stmts, notValid)
+		validations = append(validations, diff.Statement{
+			DDL:         fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s", table, constraint),
+			Timeout:     stmt.Timeout,
+			LockTimeout: stmt.LockTimeout,
+		})
+	}
+	plan.Statements = append(stmts, validations...)
+	return plan
+}
+
 func adaptStatement(stmt diff.Statement) diff.Statement {
 	for _, prefix := range []string{"CREATE INDEX", "CREATE UNIQUE INDEX", "DROP INDEX", "DROP UNIQUE INDEX"} {
 		concurrentPrefix := prefix + " CONCURRENTLY"
@@ -58,12 +199,27 @@ func adaptStatement(stmt diff.Statement) diff.Statement {
 	return stmt
 }
 
+// hazardPrefix starts the comments listing the hazards of a statement in migrations.
+const hazardPrefix = "-- [HAZARD]: "
+
+// HazardsFromSQL returns the hazards listed in the comments of a generated migration, e.g.
+// "INDEX_BUILD: This might affect database performance".
+func HazardsFromSQL(sql string) []string {
+	var hazards []string
+	for _, line := range strings.Split(sql, "\n") {
+		if hazard, ok := strings.CutPrefix(strings.TrimSpace(line), hazardPrefix); ok {
+			hazards = append(hazards, hazard)
+		}
+	}
+	return hazards
+}
+
 func statementToPrettyS(stmt diff.Statement) string {
 	sb := strings.Builder{}
 	sb.WriteString(fmt.Sprintf("%s;", stmt.DDL))
 	if len(stmt.Hazards) > 0 {
 		for _, hazard := range stmt.Hazards {
-			sb.WriteString(fmt.Sprintf("\n-- [HAZARD]: %s", hazardToPrettyS(hazard)))
+			sb.WriteString(fmt.Sprintf("\n%s%s", hazardPrefix, hazardToPrettyS(hazard)))
 		}
 	}
 	return sb.String()
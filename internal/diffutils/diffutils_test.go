@@ -0,0 +1,40 @@
+package diffutils
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestGetDDLsFromFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"schema/types.sql":  {Data: []byte("CREATE TYPE status AS ENUM ('open', 'paid');\n")},
+		"schema/tables.sql": {Data: []byte("-- Tables\nCREATE TABLE users (id int);\n\nCREATE TABLE orders (\n  id int,\n  status status\n);\n")},
+		"schema/notes.txt":  {Data: []byte("CREATE TABLE notes (id int);")},
+	}
+	ddls, err := GetDDLsFromFiles(fsys, []string{"schema/types.sql", "schema/tables.sql"})
+	if err != nil {
+		t.Fatalf("GetDDLsFromFiles() error = %v", err)
+	}
+	want := []DDL{
+		{SQL: "CREATE TYPE status AS ENUM ('open', 'paid')", File: "schema/types.sql", Line: 1},
+		{SQL: "CREATE TABLE users (id int)", File: "schema/tables.sql", Line: 2},
+		{SQL: "CREATE TABLE orders (\n  id int,\n  status status\n)", File: "schema/tables.sql", Line: 4},
+	}
+	if !slices.Equal(ddls, want) {
+		t.Errorf("GetDDLsFromFiles() = %+v, want %+v", ddls, want)
+	}
+	if got := ddls[2].Location(); got != "schema/tables.sql:4" {
+		t.Errorf("Location() = %q, want %q", got, "schema/tables.sql:4")
+	}
+
+	for path, wantErr := range map[string]string{
+		"schema/notes.txt":   `file "schema/notes.txt" is not a .sql file`,
+		"schema/missing.sql": `reading file "schema/missing.sql"`,
+	} {
+		if _, err := GetDDLsFromFiles(fsys, []string{path}); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("GetDDLsFromFiles(%q) error = %v, want %q", path, err, wantErr)
+		}
+	}
+}
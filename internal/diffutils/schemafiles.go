@@ -0,0 +1,289 @@
+package diffutils
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/cortea-ai/pg-migrant/internal/fsutil"
+	"github.com/cortea-ai/pg-migrant/internal/sqlparse"
+)
+
+// ResolveSchemaFiles expands the schema_files entries into files of fsys and sorts them so that every
+// file comes after the files defining the objects it references.
+func ResolveSchemaFiles(fsys fs.FS, entries []string) ([]string, error) {
+	paths, err := ExpandSchemaFiles(fsys, entries)
+	if err != nil {
+		return nil, err
+	}
+	return SortSchemaFiles(fsys, paths)
+}
+
+// ExpandSchemaFiles expands the schema_files entries into files of fsys, in order and without duplicates.
+// An entry is a file, a directory, whose .sql files are included recursively, or a glob pattern, in
+// which ** matches any number of directories. Directories and patterns must match at least one .sql
+// file. The paths returned are slash-separated and cleaned.
+func ExpandSchemaFiles(fsys fs.FS, entries []string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	for _, entry := range entries {
+		matches, err := expandSchemaFile(fsys, fsutil.Clean(entry))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range matches {
+			add(path)
+		}
+	}
+	return paths, nil
+}
+
+func expandSchemaFile(fsys fs.FS, entry string) ([]string, error) {
+	if !strings.ContainsAny(entry, "*?[") {
+		info, err := fs.Stat(fsys, entry)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return []string{entry}, nil
+		}
+		matches, err := walkSQLFiles(fsys, entry, func(string) bool { return true })
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("directory %q contains no .sql files", entry)
+		}
+		return matches, nil
+	}
+
+	pattern := entry
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", entry, err)
+	}
+	// Walk from the longest directory prefix without wildcards.
+	root := "."
+	if segments := strings.Split(pattern, "/"); len(segments) > 1 {
+		i := 0
+		for i < len(segments)-1 && !strings.ContainsAny(segments[i], "*?[") {
+			i++
+		}
+		if i > 0 {
+			root = strings.Join(segments[:i], "/")
+			if root == "" {
+				root = "/"
+			}
+		}
+	}
+	if _, err := fs.Stat(fsys, root); err != nil {
+		return nil, fmt.Errorf("pattern %q matches no files", entry)
+	}
+	matches, err := walkSQLFiles(fsys, root, func(name string) bool {
+		return matchPattern(strings.Split(pattern, "/"), strings.Split(name, "/"))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("pattern %q matches no .sql files", entry)
+	}
+	return matches, nil
+}
+
+// walkSQLFiles returns the .sql files of fsys under root accepted by match, in lexical order.
+func walkSQLFiles(fsys fs.FS, root string, match func(name string) bool) ([]string, error) {
+	var paths []string
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.ToLower(path.Ext(name)) == ".sql" && match(name) {
+			paths = append(paths, name)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// matchPattern matches slash-separated path segments against pattern segments, where a ** segment
+// matches zero or more path segments.
+func matchPattern(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchPattern(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// schemaFileDeps holds what a schema file defines and references.
+type schemaFileDeps struct {
+	path string
+	// defines maps the keys of the objects the file defines to their names.
+	defines    map[string]string
+	extensions bool
+	// references lists the keys of the objects the file references, in order of appearance.
+	references []string
+}
+
+// SortSchemaFiles orders schema files so that each file comes after the files defining the schemas,
+// types, tables, sequences, functions and views it references, and after all the files creating
+// extensions, whose objects cannot be known. Files are otherwise kept in their given order. A cycle
+// between files is an error naming the objects involved.
+func SortSchemaFiles(fsys fs.FS, paths []string) ([]string, error) {
+	files := make([]schemaFileDeps, len(paths))
+	definedIn := make(map[string]int)
+	for i, path := range paths {
+		content, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return nil, fmt.Errorf("reading file %q: %w", path, err)
+		}
+		files[i] = parseSchemaFileDeps(path, string(content))
+		for key := range files[i].defines {
+			if _, ok := definedIn[key]; !ok {
+				definedIn[key] = i
+			}
+		}
+	}
+
+	// deps[i] maps the files that file i depends on to the object the dependency is on.
+	deps := make([]map[int]string, len(files))
+	for i, file := range files {
+		deps[i] = make(map[int]string)
+		for _, key := range file.references {
+			j, ok := definedIn[key]
+			if !ok || j == i {
+				continue
+			}
+			if _, ok := deps[i][j]; !ok {
+				deps[i][j] = files[j].defines[key]
+			}
+		}
+	}
+	for i := range files {
+		for j, ext := range files {
+			if !ext.extensions || files[i].extensions || i == j {
+				continue
+			}
+			// An extension may be created in a schema defined by this file.
+			if _, ok := deps[j][i]; !ok {
+				if _, ok := deps[i][j]; !ok {
+					deps[i][j] = "extensions"
+				}
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(files))
+	done := make([]bool, len(files))
+	for len(sorted) < len(files) {
+		next := -1
+		for i := range files {
+			if done[i] {
+				continue
+			}
+			ready := true
+			for j := range deps[i] {
+				ready = ready && done[j]
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			return nil, dependencyCycleError(files, deps, done)
+		}
+		done[next] = true
+		sorted = append(sorted, files[next].path)
+	}
+	return sorted, nil
+}
+
+// parseSchemaFileDeps finds the objects a schema file defines and the ones it references, along with
+// their schemas. References are only taken from the positions of object names, so that keywords and
+// columns do not create dependencies.
+func parseSchemaFileDeps(path, content string) schemaFileDeps {
+	file := schemaFileDeps{path: path, defines: make(map[string]string)}
+	for _, stmt := range sqlparse.Split(content) {
+		if obj, ok := sqlparse.ParseDefinition(stmt.SQL); ok {
+			switch obj.Kind {
+			case sqlparse.KindExtension:
+				file.extensions = true
+			case sqlparse.KindSchema:
+				file.defines[schemaKey(obj.Name)] = "schema " + obj.Name
+			default:
+				file.defines[objectKey(obj.Schema, obj.Name)] = obj.String()
+			}
+		}
+		for _, ref := range sqlparse.References(stmt.SQL) {
+			if ref.Kind != sqlparse.KindSchema {
+				file.references = append(file.references, objectKey(ref.Schema, ref.Name))
+			}
+			file.references = append(file.references, schemaKey(ref.Schema))
+		}
+	}
+	return file
+}
+
+func schemaKey(schema string) string {
+	return "schema " + schema
+}
+
+func objectKey(schema, name string) string {
+	return schema + "." + name
+}
+
+// dependencyCycleError describes a cycle among the files not done yet.
+func dependencyCycleError(files []schemaFileDeps, deps []map[int]string, done []bool) error {
+	// Every remaining file depends on another remaining file, so following dependencies from any of them
+	// eventually loops.
+	start := 0
+	for done[start] {
+		start++
+	}
+	var path []int
+	visited := make(map[int]int)
+	i := start
+	for {
+		if at, ok := visited[i]; ok {
+			path = path[at:]
+			break
+		}
+		visited[i] = len(path)
+		path = append(path, i)
+		next := -1
+		for j := range deps[i] {
+			if !done[j] && (next < 0 || j < next) {
+				next = j
+			}
+		}
+		i = next
+	}
+
+	steps := make([]string, 0, len(path))
+	for k, i := range path {
+		j := path[(k+1)%len(path)]
+		steps = append(steps, fmt.Sprintf("%s references %s, defined in %s", files[i].path, deps[i][j], files[j].path))
+	}
+	return fmt.Errorf("dependency cycle between schema files: %s", strings.Join(steps, "; "))
+}
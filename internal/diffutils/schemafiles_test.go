@@ -0,0 +1,195 @@
+package diffutils
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestExpandSchemaFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"schema/extensions.sql":      {Data: []byte("CREATE EXTENSION citext;")},
+		"schema/public/users.sql":    {Data: []byte("CREATE TABLE users (id int);")},
+		"schema/public/orders.sql":   {Data: []byte("CREATE TABLE orders (id int);")},
+		"schema/billing/invoice.sql": {Data: []byte("CREATE TABLE billing.invoices (id int);")},
+		"schema/README.md":           {Data: []byte("# Schema")},
+		"empty/.keep":                {},
+	}
+	tests := []struct {
+		name    string
+		entries []string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:    "file",
+			entries: []string{"schema/extensions.sql"},
+			want:    []string{"schema/extensions.sql"},
+		},
+		{
+			name:    "directory, recursively and in lexical order",
+			entries: []string{"./schema/"},
+			want:    []string{"schema/billing/invoice.sql", "schema/extensions.sql", "schema/public/orders.sql", "schema/public/users.sql"},
+		},
+		{
+			name:    "glob",
+			entries: []string{"schema/public/*.sql"},
+			want:    []string{"schema/public/orders.sql", "schema/public/users.sql"},
+		},
+		{
+			name:    "double star matches any number of directories",
+			entries: []string{"schema/**/*.sql"},
+			want:    []string{"schema/billing/invoice.sql", "schema/extensions.sql", "schema/public/orders.sql", "schema/public/users.sql"},
+		},
+		{
+			name:    "duplicates are dropped, first position wins",
+			entries: []string{"schema/public/users.sql", "schema/public/*.sql"},
+			want:    []string{"schema/public/users.sql", "schema/public/orders.sql"},
+		},
+		{
+			name:    "missing file",
+			entries: []string{"schema/missing.sql"},
+			wantErr: "file does not exist",
+		},
+		{
+			name:    "directory without sql files",
+			entries: []string{"empty"},
+			wantErr: `directory "empty" contains no .sql files`,
+		},
+		{
+			name:    "pattern without sql files",
+			entries: []string{"schema/*.txt"},
+			wantErr: `pattern "schema/*.txt" matches no .sql files`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandSchemaFiles(fsys, tt.entries)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ExpandSchemaFiles() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExpandSchemaFiles() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ExpandSchemaFiles() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveSchemaFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"db/schema/1_tables.sql":  {Data: []byte("CREATE TABLE app.users (id int, role app.role);")},
+		"db/schema/2_types.sql":   {Data: []byte("CREATE TYPE app.role AS ENUM ('admin', 'member');")},
+		"db/schema/3_schemas.sql": {Data: []byte("CREATE SCHEMA app;")},
+	}
+	paths, err := ResolveSchemaFiles(fsys, []string{"./db/schema"})
+	if err != nil {
+		t.Fatalf("ResolveSchemaFiles() error = %v", err)
+	}
+	want := []string{"db/schema/3_schemas.sql", "db/schema/2_types.sql", "db/schema/1_tables.sql"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("ResolveSchemaFiles() = %q, want %q", paths, want)
+	}
+}
+
+func TestSortSchemaFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		paths []string
+		want  []string
+	}{
+		{
+			name: "foreign key",
+			files: map[string]string{
+				"orders.sql": "CREATE TABLE orders (id int, user_id int REFERENCES users (id));",
+				"users.sql":  "CREATE TABLE users (id int PRIMARY KEY);",
+			},
+			paths: []string{"orders.sql", "users.sql"},
+			want:  []string{"users.sql", "orders.sql"},
+		},
+		{
+			name: "schema, type and function",
+			files: map[string]string{
+				"a_tables.sql":    "CREATE TABLE billing.invoices (id int, status billing.status DEFAULT billing.default_status());",
+				"b_functions.sql": "CREATE FUNCTION billing.default_status() RETURNS billing.status LANGUAGE sql AS $$ SELECT 'open'::billing.status $$;",
+				"c_types.sql":     "CREATE TYPE billing.status AS ENUM ('open', 'paid');",
+				"d_schemas.sql":   "CREATE SCHEMA billing;",
+			},
+			paths: []string{"a_tables.sql", "b_functions.sql", "c_types.sql", "d_schemas.sql"},
+			want:  []string{"d_schemas.sql", "c_types.sql", "b_functions.sql", "a_tables.sql"},
+		},
+		{
+			name: "sequence given by name",
+			files: map[string]string{
+				"users.sql":     "CREATE TABLE users (id bigint DEFAULT nextval('users_id_seq'::regclass));",
+				"sequences.sql": "CREATE SEQUENCE users_id_seq;",
+			},
+			paths: []string{"users.sql", "sequences.sql"},
+			want:  []string{"sequences.sql", "users.sql"},
+		},
+		{
+			name: "extensions come first",
+			files: map[string]string{
+				"users.sql":      "CREATE TABLE users (email citext);",
+				"extensions.sql": "CREATE EXTENSION citext;",
+			},
+			paths: []string{"users.sql", "extensions.sql"},
+			want:  []string{"extensions.sql", "users.sql"},
+		},
+		{
+			name: "columns and keywords named like objects are no references",
+			files: map[string]string{
+				// Tables named like a column, a keyword and a join alias of the other file.
+				"a.sql": "CREATE TABLE status (id int); CREATE TABLE key (id int); CREATE TABLE u (id int);",
+				"b.sql": `CREATE TABLE accounts (id int PRIMARY KEY, status text, key text);
+CREATE VIEW active AS SELECT a.id FROM accounts a JOIN accounts u ON a.id = u.id WHERE a.status = 'active';`,
+			},
+			paths: []string{"b.sql", "a.sql"},
+			want:  []string{"b.sql", "a.sql"},
+		},
+		{
+			name: "view referencing another one",
+			files: map[string]string{
+				"reports.sql": "CREATE VIEW reports AS SELECT * FROM active_users;",
+				"active.sql":  "CREATE VIEW active_users AS SELECT * FROM users; CREATE TABLE users (id int);",
+			},
+			paths: []string{"reports.sql", "active.sql"},
+			want:  []string{"active.sql", "reports.sql"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for path, content := range tt.files {
+				fsys[path] = &fstest.MapFile{Data: []byte(content)}
+			}
+			got, err := SortSchemaFiles(fsys, tt.paths)
+			if err != nil {
+				t.Fatalf("SortSchemaFiles() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("SortSchemaFiles() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortSchemaFilesCycle(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.sql": {Data: []byte("CREATE TABLE a (id int, b_id int REFERENCES b (id));")},
+		"b.sql": {Data: []byte("CREATE TABLE b (id int, a_id int REFERENCES a (id));")},
+		"c.sql": {Data: []byte("CREATE TABLE c (id int, a_id int REFERENCES a (id));")},
+	}
+	_, err := SortSchemaFiles(fsys, []string{"c.sql", "a.sql", "b.sql"})
+	want := "dependency cycle between schema files: a.sql references public.b, defined in b.sql; b.sql references public.a, defined in a.sql"
+	if err == nil || err.Error() != want {
+		t.Errorf("SortSchemaFiles() error = %v, want %q", err, want)
+	}
+}
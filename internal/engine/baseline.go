@@ -0,0 +1,50 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/cortea-ai/pg-migrant/internal/db"
+)
+
+const BaselineSuffix = "_baseline"
+
+// IsBaseline reports whether the migration file collapses all earlier migrations.
+func IsBaseline(filename string) bool {
+	return strings.HasSuffix(strings.TrimSuffix(filename, filepath.Ext(filename)), BaselineSuffix)
+}
+
+// ResolveBaseline records the latest baseline as satisfied on databases that already ran the migrations
+// it replaced, and refuses to run it on databases that only ran some of them.
+func ResolveBaseline(ctx context.Context, conn *db.Conn, logger *slog.Logger, currentVersion string, migrations []Migration, dryRun bool) error {
+	var baseline *Migration
+	for i := range migrations {
+		if IsBaseline(migrations[i].Filename) {
+			baseline = &migrations[i]
+		}
+	}
+	if baseline == nil || currentVersion == "" {
+		return nil
+	}
+	if baseline.Version > currentVersion {
+		return fmt.Errorf("database is at version %s, which predates baseline %s: restore the migrations it replaced from version control and apply them first", currentVersion, baseline.Filename)
+	}
+	if dryRun {
+		return nil
+	}
+	recorded, err := conn.HasHistory(ctx, baseline.Version)
+	if err != nil {
+		return err
+	}
+	if recorded {
+		return nil
+	}
+	if err := conn.MarkBaselineSatisfied(ctx, baseline.Version, baseline.Content); err != nil {
+		return err
+	}
+	logger.Info("marked baseline as satisfied", "version", baseline.Version)
+	return nil
+}
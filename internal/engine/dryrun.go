@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	"github.com/cortea-ai/pg-migrant/internal/db"
+	"github.com/cortea-ai/pg-migrant/internal/sqlparse"
+	"github.com/jackc/pgx/v5"
+)
+
+// dryRunMigration executes the statements of a migration in tx, up to the first failing one. Statements
+// that cannot run in a transaction are skipped. A Go migration is reported as a single statement.
+func dryRunMigration(ctx context.Context, tx pgx.Tx, m Migration) []StatementResult {
+	if m.Func != nil {
+		start := time.Now()
+		err := m.Func(ctx, tx)
+		return []StatementResult{{SQL: m.Content, Duration: time.Since(start), Err: err}}
+	}
+	var results []StatementResult
+	nonTransactional := db.IsNonTransactional(m.Content)
+	for _, stmt := range sqlparse.Split(m.Content) {
+		skipped := nonTransactional || isNonTransactionalStatement(stmt.SQL)
+		result := StatementResult{Line: stmt.Line, SQL: stmt.SQL, Skipped: skipped}
+		if !skipped {
+			start := time.Now()
+			tag, err := tx.Exec(ctx, stmt.SQL)
+			result.Duration, result.Err = time.Since(start), err
+			result.RowsAffected = tag.RowsAffected()
+		}
+		results = append(results, result)
+		if result.Err != nil {
+			break
+		}
+	}
+	return results
+}
+
+// nonTransactionalKeywords starts the keyword sequences of the statements PostgreSQL refuses to run in
+// a transaction block.
+var nonTransactionalKeywords = [][]string{
+	{"CONCURRENTLY"},
+	{"VACUUM"},
+	{"CREATE", "DATABASE"},
+	{"DROP", "DATABASE"},
+	{"CREATE", "TABLESPACE"},
+	{"DROP", "TABLESPACE"},
+	{"ALTER", "SYSTEM"},
+}
+
+// isNonTransactionalStatement reports whether a statement cannot run in a transaction, e.g. CREATE INDEX
+// CONCURRENTLY or VACUUM in a migration without the no-transaction marker.
+func isNonTransactionalStatement(sql string) bool {
+	// Unlike the other concurrent statements, this one runs in a transaction.
+	if sqlparse.HasKeywords(sql, "REFRESH", "MATERIALIZED", "VIEW", "CONCURRENTLY") {
+		return false
+	}
+	for _, keywords := range nonTransactionalKeywords {
+		if sqlparse.HasKeywords(sql, keywords...) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,421 @@
+// Package engine finds, applies, dry-runs and plans the migrations of an env for the commands and
+// pkg/migrant. Its functions do not print nor prompt: they report through their results, errors and
+// logger, so that pkg/migrant can expose them and the commands can print them.
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/cortea-ai/pg-migrant/internal/config"
+	"github.com/cortea-ai/pg-migrant/internal/db"
+	"github.com/cortea-ai/pg-migrant/internal/diffutils"
+	"github.com/cortea-ai/pg-migrant/internal/metrics"
+	"github.com/cortea-ai/pg-migrant/internal/notify"
+	"github.com/cortea-ai/pg-migrant/internal/tracing"
+	"github.com/jackc/pgx/v5"
+	"github.com/stripe/pg-schema-diff/pkg/diff"
+)
+
+// ErrAborted is returned when a migration is not approved.
+var ErrAborted = errors.New("migration aborted")
+
+// ErrNoSchemaFiles is returned when a plan is requested for an env without schema files.
+var ErrNoSchemaFiles = errors.New("no schema files provided")
+
+// MigrationError is returned when a migration fails.
+type MigrationError struct {
+	Migration Migration
+	Err       error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("migration %s failed: %v", e.Migration.Filename, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// PendingSet is what remains to be applied to a database.
+type PendingSet struct {
+	CurrentVersion string
+	Migrations     []Migration
+	// Repeatables lists the repeatable migrations that changed since they were last applied.
+	Repeatables []Migration
+}
+
+// Empty reports whether nothing is pending.
+func (p PendingSet) Empty() bool {
+	return len(p.Migrations) == 0 && len(p.Repeatables) == 0
+}
+
+func discardLogger(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return logger
+}
+
+// FindPending returns the migrations pending on the database of the env. The database is only read.
+func FindPending(ctx context.Context, conf *config.Config, logger *slog.Logger) (PendingSet, error) {
+	conn, err := db.NewConn(ctx, conf.GetDBUrl())
+	if err != nil {
+		return PendingSet{}, err
+	}
+	defer conn.Close(ctx)
+	return findPending(ctx, conn, conf, discardLogger(logger), true)
+}
+
+// findPending reads the current version of the database and the migrations pending on it. Unless
+// dryRun is set, the latest baseline is recorded as satisfied on databases that ran the migrations it
+// replaced.
+func findPending(ctx context.Context, conn *db.Conn, conf *config.Config, logger *slog.Logger, dryRun bool) (PendingSet, error) {
+	currentVersion, err := conn.CheckCurrentVersion(ctx)
+	if err != nil && !errors.Is(err, db.ErrTableNotFound) {
+		return PendingSet{}, err
+	}
+	all, err := LoadMigrations(conf.GetFS(), conf.GetMigrationDir())
+	if err != nil {
+		return PendingSet{}, err
+	}
+	if err := ResolveBaseline(ctx, conn, logger, currentVersion, all, dryRun); err != nil {
+		return PendingSet{}, err
+	}
+	repeatables, err := PendingRepeatables(ctx, conn, conf.GetFS(), conf.GetRepeatableDir())
+	if err != nil {
+		return PendingSet{}, err
+	}
+	return PendingSet{
+		CurrentVersion: currentVersion,
+		Migrations:     PendingAfter(currentVersion, all),
+		Repeatables:    repeatables,
+	}, nil
+}
+
+// ApplyOptions configures ApplyMigrations.
+type ApplyOptions struct {
+	// Approve is called before each migration is applied. ApplyMigrations stops with ErrAborted when it
+	// returns false. Every migration is approved if nil.
+	Approve func(ctx context.Context, m Migration) (bool, error)
+	// Logger receives the progress of the run. Nothing is logged if nil.
+	Logger *slog.Logger
+	// Metrics receives the metrics of the run. Nothing is recorded if nil.
+	Metrics *metrics.Registry
+}
+
+// AppliedMigration is a migration applied by ApplyMigrations.
+type AppliedMigration struct {
+	Migration
+	Duration time.Duration
+}
+
+// ApplyMigrations applies the pending migrations of the env, then its changed repeatable migrations,
+// while holding the migration lock. It returns the migrations applied, also when a later one fails, in
+// which case the error is a *MigrationError. The webhooks of the notify block of the env are notified
+// of the start, success or failure of the run, and of drift found after it. When migrations are pending,
+// the hooks of the hooks block of the env run around them, while holding the lock.
+func ApplyMigrations(ctx context.Context, conf *config.Config, opts ApplyOptions) (applied []AppliedMigration, err error) {
+	logger := discardLogger(opts.Logger)
+	reg := opts.Metrics
+	if reg == nil {
+		reg = metrics.New()
+	}
+	runStart := time.Now()
+	notifier := newNotifier(conf, logger)
+	var pending PendingSet
+	defer func() {
+		if err == nil {
+			return
+		}
+		event := migrationEvent(notify.EventFailure, pending.CurrentVersion, nil)
+		for _, a := range applied {
+			event.Versions = append(event.Versions, a.Version)
+		}
+		var migrationErr *MigrationError
+		if errors.As(err, &migrationErr) {
+			event.ToVersion = migrationErr.Migration.Version
+			event.Hazards = diffutils.HazardsFromSQL(migrationErr.Migration.Content)
+		}
+		event.Duration, event.Error = time.Since(runStart), err.Error()
+		notifier.notify(ctx, event)
+	}()
+	connCtx, span := tracing.Start(ctx, "db.connect")
+	conn, _, err := db.NewConnEnsureVersionTable(connCtx, conf.GetDBUrl())
+	tracing.End(span, err)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+	conn.Logger = logger
+	conn.StatementHook = func(ctx context.Context, version string, statement int, sql string, exec func(ctx context.Context) error) error {
+		ctx, span := tracing.Start(ctx, "migration.statement",
+			tracing.VersionKey.String(version), tracing.StatementKey.Int(statement), tracing.SQL(sql))
+		start := time.Now()
+		err := exec(ctx)
+		reg.Set(metrics.StatementDuration, time.Since(start).Seconds(), "version", version, "statement", strconv.Itoa(statement))
+		tracing.End(span, err)
+		return err
+	}
+	lockCtx, span := tracing.Start(ctx, "migration.lock")
+	lockStart := time.Now()
+	unlock, err := conn.Lock(lockCtx, func() {
+		span.AddEvent("waiting for another run")
+		logger.Info("waiting for another pg-migrant run to release the migration lock")
+	})
+	tracing.End(span, err)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	reg.Set(metrics.LockWait, time.Since(lockStart).Seconds())
+	// The current version is read under the lock, as another run may have applied migrations meanwhile.
+	pending, err = findPending(ctx, conn, conf, logger, false)
+	if err != nil {
+		return nil, err
+	}
+	remaining := len(pending.Migrations) + len(pending.Repeatables)
+	reg.Set(metrics.PendingMigrations, float64(remaining))
+	reg.Add(metrics.MigrationsApplied, 0)
+	reg.Add(metrics.MigrationFailures, 0)
+	all := append(pending.Migrations, pending.Repeatables...)
+	hooksConf := conf.GetHooksConfig()
+	hooks := hookRunner{conn: conn, logger: logger}
+	vars := runHookVars(conf, migrationEvent("", pending.CurrentVersion, all))
+	if pending.Empty() {
+		logger.Info("no pending migrations", "version", pending.CurrentVersion)
+	} else {
+		logger.Info("applying migrations", "version", pending.CurrentVersion,
+			"migrations", len(pending.Migrations), "repeatables", len(pending.Repeatables))
+		notifier.notify(ctx, migrationEvent(notify.EventStart, pending.CurrentVersion, all))
+		defer func() {
+			if err != nil {
+				hooks.runOnFailure(ctx, hooksConf.OnFailure, vars, err)
+			}
+		}()
+		if err := hooks.run(ctx, "before_apply", hooksConf.BeforeApply, vars); err != nil {
+			return nil, err
+		}
+	}
+
+	// Repeatable migrations run after the versioned ones, which may create the objects they depend on.
+	for _, m := range all {
+		if opts.Approve != nil {
+			ok, err := opts.Approve(ctx, m)
+			if err != nil {
+				return applied, err
+			}
+			if !ok {
+				return applied, ErrAborted
+			}
+		}
+		migrationCtx, span := tracing.Start(ctx, "migration.apply",
+			tracing.VersionKey.String(m.Version), tracing.FileKey.String(m.Filename))
+		start := time.Now()
+		switch {
+		case m.Repeatable:
+			err = conn.ApplyRepeatable(migrationCtx, m.Filename, m.Content)
+		case m.Func != nil:
+			err = conn.ApplyGoMigration(migrationCtx, m.Version, m.Content, m.Func)
+		default:
+			err = conn.ApplyMigration(migrationCtx, m.Version, m.Content)
+		}
+		duration := time.Since(start)
+		tracing.End(span, err)
+		if err != nil {
+			reg.Add(metrics.MigrationFailures, 1)
+			logger.Error("migration failed", "version", m.Version, "file", m.Filename, "error", err)
+			return applied, &MigrationError{Migration: m, Err: err}
+		}
+		applied = append(applied, AppliedMigration{Migration: m, Duration: duration})
+		remaining--
+		reg.Add(metrics.MigrationsApplied, 1)
+		reg.Set(metrics.MigrationDuration, duration.Seconds(), "version", m.Version, "file", m.Filename)
+		reg.Set(metrics.PendingMigrations, float64(remaining))
+		logger.Info("applied migration", "version", m.Version, "file", m.Filename, "duration", duration)
+		migrationVars := vars.withMigration(m).with("PGMIGRANT_DURATION", formatSeconds(duration))
+		if err := hooks.run(ctx, "after_each", hooksConf.AfterEach, migrationVars); err != nil {
+			return applied, err
+		}
+	}
+	if !pending.Empty() {
+		runVars := vars.with("PGMIGRANT_DURATION", formatSeconds(time.Since(runStart)))
+		if err := hooks.run(ctx, "after_apply", hooksConf.AfterApply, runVars); err != nil {
+			return applied, err
+		}
+		event := migrationEvent(notify.EventSuccess, pending.CurrentVersion, all)
+		event.Duration = time.Since(runStart)
+		notifier.notify(ctx, event)
+	}
+	if notifier.enabled() {
+		drift, hazards, err := detectDrift(ctx, conn, conf, logger)
+		if err != nil {
+			// The migrations were applied: only the check failed.
+			logger.Warn("checking drift failed", "error", err)
+		} else if len(drift) > 0 {
+			logger.Warn("drift detected", "drift", drift)
+			notifier.notify(ctx, notify.Event{Event: notify.EventDrift, ToVersion: migrationEvent("", pending.CurrentVersion, all).ToVersion, Drift: drift, Hazards: hazards})
+		}
+	}
+	return applied, nil
+}
+
+// DryRunResult is a migration executed by DryRunMigrations.
+type DryRunResult struct {
+	Migration
+	Statements []StatementResult
+	// Skipped is set for non-transactional migrations, which cannot run in the transaction.
+	Skipped bool
+}
+
+// DryRunMigrations executes the pending migrations of the env, then its changed repeatable migrations,
+// in a single transaction that is rolled back, so that each migration sees the changes of the previous
+// ones. It stops at the first failing statement, returning the results up to it and a *MigrationError.
+func DryRunMigrations(ctx context.Context, conf *config.Config, logger *slog.Logger) ([]DryRunResult, error) {
+	logger = discardLogger(logger)
+	conn, err := db.NewConn(ctx, conf.GetDBUrl())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+	pending, err := findPending(ctx, conn, conf, logger, true)
+	if err != nil || pending.Empty() {
+		return nil, err
+	}
+	var results []DryRunResult
+	err = conn.DryRun(ctx, func(tx pgx.Tx) error {
+		for _, m := range append(pending.Migrations, pending.Repeatables...) {
+			result := DryRunResult{Migration: m, Skipped: db.IsNonTransactional(m.Content)}
+			result.Statements = dryRunMigration(ctx, tx, m)
+			results = append(results, result)
+			LogStatementResults(logger, m, result.Statements)
+			if n := len(result.Statements); n > 0 && result.Statements[n-1].Err != nil {
+				return &MigrationError{Migration: m, Err: result.Statements[n-1].Err}
+			}
+			logger.Info("executed migration", "version", m.Version, "file", m.Filename, "skipped", result.Skipped)
+		}
+		return nil
+	})
+	return results, err
+}
+
+// StatusReport is the state of the migrations of a database.
+type StatusReport struct {
+	PendingSet
+	// History lists the migrations recorded in the database, ordered by version.
+	History []db.HistoryEntry
+	// Modified lists the versions of the applied migrations whose content changed since.
+	Modified []string
+}
+
+// Status reports the current version of the database of the env, its migration history and the
+// pending migrations. The database is only read.
+func Status(ctx context.Context, conf *config.Config, logger *slog.Logger) (StatusReport, error) {
+	conn, err := db.NewConn(ctx, conf.GetDBUrl())
+	if err != nil {
+		return StatusReport{}, err
+	}
+	defer conn.Close(ctx)
+	pending, err := findPending(ctx, conn, conf, discardLogger(logger), true)
+	if err != nil {
+		return StatusReport{}, err
+	}
+	report := StatusReport{PendingSet: pending}
+	if _, err := conn.CheckCurrentVersion(ctx); errors.Is(err, db.ErrTableNotFound) {
+		// pg-migrant never ran against the database.
+		return report, nil
+	}
+	if report.History, err = conn.History(ctx); err != nil {
+		return StatusReport{}, fmt.Errorf("reading migration history: %w", err)
+	}
+	migrations, err := LoadMigrations(conf.GetFS(), conf.GetMigrationDir())
+	if err != nil {
+		return StatusReport{}, err
+	}
+	report.Modified = modifiedVersions(report.History, migrations)
+	return report, nil
+}
+
+// modifiedVersions returns the versions of the applied migrations whose content changed since.
+func modifiedVersions(history []db.HistoryEntry, migrations []Migration) []string {
+	checksums := make(map[string]string, len(migrations))
+	for _, m := range migrations {
+		checksums[m.Version] = db.Checksum(m.Content)
+	}
+	var modified []string
+	for _, e := range history {
+		if checksum, ok := checksums[e.Version]; ok && e.Status == db.StatusApplied && checksum != e.Checksum {
+			modified = append(modified, e.Version)
+		}
+	}
+	return modified
+}
+
+// GeneratedPlan is the plan turning the schema of a database into the one of the schema files.
+type GeneratedPlan struct {
+	diff.Plan
+	// Migrations holds the content of the migration files the plan is written as, in order.
+	Migrations []string
+}
+
+// GeneratePlan diffs the database of the env against its schema files, loaded into a temp database. The
+// database is only read.
+func GeneratePlan(ctx context.Context, conf *config.Config, logger *slog.Logger) (GeneratedPlan, error) {
+	logger = discardLogger(logger)
+	if len(conf.GetSchemaFiles()) == 0 {
+		return GeneratedPlan{}, ErrNoSchemaFiles
+	}
+
+	tempDbFactory, err := NewTempDbFactory(ctx, conf)
+	if err != nil {
+		return GeneratedPlan{}, err
+	}
+	defer CloseTempDbFactory(logger, tempDbFactory)
+
+	conn, err := db.NewConn(ctx, conf.GetDBUrl())
+	if err != nil {
+		return GeneratedPlan{}, err
+	}
+	defer conn.Close(ctx)
+
+	schemaFiles, err := diffutils.ResolveSchemaFiles(conf.GetFS(), conf.GetSchemaFiles())
+	if err != nil {
+		return GeneratedPlan{}, fmt.Errorf("resolving schema files: %w", err)
+	}
+	ddls, err := diffutils.GetDDLsFromFiles(conf.GetFS(), schemaFiles)
+	if err != nil {
+		return GeneratedPlan{}, err
+	}
+
+	// Load the schema files ourselves rather than through a DDL schema source, so that a failing statement
+	// can be located in its file.
+	schemaDb, err := tempDbFactory.Create(ctx)
+	if err != nil {
+		return GeneratedPlan{}, fmt.Errorf("creating temp database: %w", err)
+	}
+	defer CloseTempDb(ctx, logger, schemaDb)
+	if err := loadDDLs(ctx, schemaDb, ddls); err != nil {
+		return GeneratedPlan{}, fmt.Errorf("loading schema files: %w", err)
+	}
+
+	diffConf := conf.GetDiffConfig()
+	plan, err := diff.Generate(ctx, conn, diff.DBSchemaSource(schemaDb.ConnPool), append(PlanOpts(diffConf),
+		diff.WithGetSchemaOpts(append(SchemaFilterOpts(conf), schemaDb.ExcludeMetadataOptions...)...),
+		diff.WithTempDbFactory(tempDbFactory),
+	)...)
+	if err != nil {
+		return GeneratedPlan{}, err
+	}
+	plan = AdaptPlan(diffConf, plan)
+	generated := GeneratedPlan{Plan: plan}
+	if len(plan.Statements) > 0 {
+		generated.Migrations = PlanToMigrations(diffConf, plan)
+	}
+	logger.Info("generated plan", "statements", len(plan.Statements), "migrations", len(generated.Migrations))
+	return generated, nil
+}
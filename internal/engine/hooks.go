@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cortea-ai/pg-migrant/internal/config"
+	"github.com/cortea-ai/pg-migrant/internal/db"
+	"github.com/cortea-ai/pg-migrant/internal/notify"
+	"github.com/cortea-ai/pg-migrant/internal/sqlparse"
+	"github.com/cortea-ai/pg-migrant/internal/tracing"
+)
+
+// maxHookOutput is the length of the end of the output of a hook command that is logged.
+const maxHookOutput = 2048
+
+// hookVars are the environment variables describing a run to hook commands.
+type hookVars map[string]string
+
+// runHookVars returns the variables of the run described by e.
+func runHookVars(conf *config.Config, e notify.Event) hookVars {
+	return hookVars{
+		"PGMIGRANT_ENV":          conf.SelectedEnv.Name,
+		"PGMIGRANT_DB_URL":       conf.GetDBUrl(),
+		"PGMIGRANT_FROM_VERSION": e.FromVersion,
+		"PGMIGRANT_TO_VERSION":   e.ToVersion,
+		"PGMIGRANT_VERSIONS":     strings.Join(e.Versions, " "),
+	}
+}
+
+// withMigration returns the variables with the ones of m added.
+func (v hookVars) withMigration(m Migration) hookVars {
+	return v.with("PGMIGRANT_VERSION", m.Version, "PGMIGRANT_FILE", m.Filename)
+}
+
+// with returns the variables with the pairs of keys and values kv added.
+func (v hookVars) with(kv ...string) hookVars {
+	vars := maps.Clone(v)
+	for i := 0; i+1 < len(kv); i += 2 {
+		vars[kv[i]] = kv[i+1]
+	}
+	return vars
+}
+
+func (v hookVars) environ() []string {
+	env := os.Environ()
+	for _, k := range slices.Sorted(maps.Keys(v)) {
+		env = append(env, k+"="+v[k])
+	}
+	return env
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// hookRunner runs the hooks of the hooks block of the env.
+type hookRunner struct {
+	conn   *db.Conn
+	logger *slog.Logger
+}
+
+// run runs the hooks of a phase in order. A failing hook with the fail policy stops the phase and its
+// error is returned; other failures are logged.
+func (r hookRunner) run(ctx context.Context, phase string, hooks []config.HookConfig, vars hookVars) error {
+	vars = vars.with("PGMIGRANT_HOOK", phase)
+	for i, hook := range hooks {
+		if err := r.runHook(ctx, phase, i+1, hook, vars); err != nil {
+			err = fmt.Errorf("%s hook %d: %w", phase, i+1, err)
+			if hook.GetOnError() == config.HookFail {
+				return err
+			}
+			r.logFailure(hook, err)
+		}
+	}
+	return nil
+}
+
+// runOnFailure runs the on_failure hooks, logging their failures, as the run already failed. They run
+// even when the run was interrupted, e.g. to resume what before_apply hooks paused.
+func (r hookRunner) runOnFailure(ctx context.Context, hooks []config.HookConfig, vars hookVars, runErr error) {
+	ctx = context.WithoutCancel(ctx)
+	vars = vars.with("PGMIGRANT_HOOK", "on_failure", "PGMIGRANT_ERROR", config.Redact(runErr.Error()))
+	var migrationErr *MigrationError
+	if errors.As(runErr, &migrationErr) {
+		vars = vars.withMigration(migrationErr.Migration)
+	}
+	for i, hook := range hooks {
+		if err := r.runHook(ctx, "on_failure", i+1, hook, vars); err != nil {
+			r.logFailure(hook, fmt.Errorf("on_failure hook %d: %w", i+1, err))
+		}
+	}
+}
+
+// logFailure logs the failure of a hook at the level of its policy.
+func (r hookRunner) logFailure(hook config.HookConfig, err error) {
+	switch hook.GetOnError() {
+	case config.HookIgnore:
+		r.logger.Debug("hook failed", "error", err)
+	case config.HookWarn:
+		r.logger.Warn("hook failed", "error", err)
+	default:
+		r.logger.Error("hook failed", "error", err)
+	}
+}
+
+func (r hookRunner) runHook(ctx context.Context, phase string, index int, hook config.HookConfig, vars hookVars) (err error) {
+	ctx, span := tracing.Start(ctx, "migration.hook", tracing.HookKey.String(phase))
+	defer func() { tracing.End(span, err) }()
+	if timeout := hook.GetTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	if hook.SQL != "" {
+		if err := r.execSQL(ctx, hook.SQL); err != nil {
+			return err
+		}
+		r.logger.Info("ran hook", "hook", phase, "index", index, "duration", time.Since(start))
+		return nil
+	}
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook.Command)
+	cmd.Env = vars.environ()
+	cmd.Stdout, cmd.Stderr = &output, &output
+	// Do not wait for the children left holding the output once the command is killed.
+	cmd.WaitDelay = time.Second
+	err = cmd.Run()
+	out := strings.TrimSpace(output.String())
+	if len(out) > maxHookOutput {
+		out = "..." + strings.ToValidUTF8(out[len(out)-maxHookOutput:], "")
+	}
+	if hook.GetTimeout() > 0 && ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", hook.GetTimeout())
+	}
+	if err != nil {
+		if out != "" {
+			return fmt.Errorf("%w: %s", err, out)
+		}
+		return err
+	}
+	r.logger.Info("ran hook", "hook", phase, "index", index, "duration", time.Since(start), "output", out)
+	return nil
+}
+
+// execSQL executes the statements of sql one at a time, outside of a transaction, so that statements
+// such as REFRESH MATERIALIZED VIEW CONCURRENTLY can run.
+func (r hookRunner) execSQL(ctx context.Context, sql string) error {
+	conn, err := r.conn.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring connection: %w", err)
+	}
+	defer conn.Close()
+	for i, stmt := range sqlparse.Split(sql) {
+		if _, err := conn.ExecContext(ctx, stmt.SQL); err != nil {
+			return fmt.Errorf("statement %d (line %d): %w", i+1, stmt.Line, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cortea-ai/pg-migrant/internal/fsutil"
+	"github.com/cortea-ai/pg-migrant/internal/migrate"
+)
+
+// Migration is a migration of the migration directory, a registered Go migration, or a repeatable
+// migration.
+type Migration struct {
+	Filename string
+	Version  string
+	Content  string
+	// Func is set for migrations written in Go, whose Content only describes them.
+	Func migrate.Func
+	// Repeatable is set for repeatable migrations, which have no version.
+	Repeatable bool
+}
+
+// PendingAfter returns the migrations with a version greater than currentVersion.
+func PendingAfter(currentVersion string, migrations []Migration) []Migration {
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > currentVersion {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// LoadMigrations returns the migrations of migrationDir in fsys along with the Go migrations registered
+// with migrant.Register, ordered by version.
+func LoadMigrations(fsys fs.FS, migrationDir string) ([]Migration, error) {
+	migrations, err := ReadMigrations(fsys, migrationDir)
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[string]string, len(migrations))
+	for _, m := range migrations {
+		versions[m.Version] = m.Filename
+	}
+	for _, m := range migrate.Registered() {
+		if err := ValidateVersion(m.Version); err != nil {
+			return nil, fmt.Errorf("Go migration %s: %w", m.Name, err)
+		}
+		if filename, ok := versions[m.Version]; ok {
+			return nil, fmt.Errorf("Go migration %s has the same version as %s", m.Name, filename)
+		}
+		migrations = append(migrations, Migration{
+			Filename: fmt.Sprintf("%s (%s)", m.Version, m.Name),
+			Version:  m.Version,
+			Content:  fmt.Sprintf("-- Go migration %s\n", m.Name),
+			Func:     m.Func,
+		})
+	}
+	sort.SliceStable(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// ReadMigrations returns every migration in migrationDir in fsys, ordered by filename.
+func ReadMigrations(fsys fs.FS, migrationDir string) ([]Migration, error) {
+	migrationDir = fsutil.Clean(migrationDir)
+	files, err := fs.ReadDir(fsys, migrationDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read local migration directory: %w", err)
+	}
+	migrations := make([]Migration, 0)
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, path.Join(migrationDir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration file %s: %w", file.Name(), err)
+		}
+		version, err := VersionFromFilename(file.Name())
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{
+			Filename: file.Name(),
+			Version:  version,
+			Content:  string(content),
+		})
+	}
+	return migrations, nil
+}
+
+func VersionFromFilename(filename string) (string, error) {
+	filenameWithoutExt := strings.TrimSuffix(filename, filepath.Ext(filename))
+	parts := strings.Split(filenameWithoutExt, "_")
+	if len(parts) == 0 {
+		return "", fmt.Errorf("invalid filename: %s", filename)
+	}
+	if err := ValidateVersion(parts[0]); err != nil {
+		return "", err
+	}
+	return parts[0], nil
+}
+
+func ValidateVersion(version string) error {
+	if len(version) != 4 {
+		return fmt.Errorf("version must be 4 characters long: %s", version)
+	}
+	_, err := strconv.Atoi(version)
+	if err != nil {
+		return fmt.Errorf("version must be numeric: %s", version)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/cortea-ai/pg-migrant/internal/migrate"
+	"github.com/jackc/pgx/v5"
+)
+
+func migrationFilenames(migrations []Migration) []string {
+	var names []string
+	for _, m := range migrations {
+		names = append(names, m.Filename)
+	}
+	return names
+}
+
+func TestReadMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		dir     string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "ordered by filename",
+			files: fstest.MapFS{
+				"migrations/0002_orders.sql": {Data: []byte("CREATE TABLE orders (id int);")},
+				"migrations/0001_users.sql":  {Data: []byte("CREATE TABLE users (id int);")},
+				"migrations/0010_items.sql":  {Data: []byte("CREATE TABLE items (id int);")},
+			},
+			dir:  "migrations",
+			want: []string{"0001_users.sql", "0002_orders.sql", "0010_items.sql"},
+		},
+		{
+			name: "config paths and subdirectories",
+			files: fstest.MapFS{
+				"db/migrations/0001_users.sql":     {Data: []byte("CREATE TABLE users (id int);")},
+				"db/migrations/archive/0000_x.sql": {Data: []byte("SELECT 1;")},
+			},
+			dir:  "./db/migrations/",
+			want: []string{"0001_users.sql"},
+		},
+		{
+			name:    "missing directory",
+			files:   fstest.MapFS{},
+			dir:     "migrations",
+			wantErr: "failed to read local migration directory",
+		},
+		{
+			name: "invalid version",
+			files: fstest.MapFS{
+				"migrations/1_users.sql": {Data: []byte("CREATE TABLE users (id int);")},
+			},
+			dir:     "migrations",
+			wantErr: "version must be 4 characters long: 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadMigrations(tt.files, tt.dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ReadMigrations() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadMigrations() error = %v", err)
+			}
+			if names := migrationFilenames(got); !slices.Equal(names, tt.want) {
+				t.Errorf("ReadMigrations() = %q, want %q", names, tt.want)
+			}
+			for _, m := range got {
+				if m.Version != m.Filename[:4] || m.Content == "" {
+					t.Errorf("ReadMigrations() returned %+v, want its version and content", m)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0001_users.sql":  {Data: []byte("CREATE TABLE users (id int);")},
+		"migrations/0003_orders.sql": {Data: []byte("CREATE TABLE orders (id int);")},
+	}
+	migrate.Register("0902", func(ctx context.Context, tx pgx.Tx) error { return nil })
+
+	migrations, err := LoadMigrations(fsys, "migrations")
+	if err != nil {
+		t.Fatalf("LoadMigrations() error = %v", err)
+	}
+	var versions []string
+	for _, m := range migrations {
+		versions = append(versions, m.Version)
+	}
+	if want := []string{"0001", "0003", "0902"}; !slices.Equal(versions, want) {
+		t.Fatalf("LoadMigrations() versions = %q, want %q", versions, want)
+	}
+	if goMigration := migrations[2]; goMigration.Func == nil || !strings.HasPrefix(goMigration.Filename, "0902 (") {
+		t.Errorf("LoadMigrations() Go migration = %+v, want its function and version", goMigration)
+	}
+
+	pending := PendingAfter("0001", migrations)
+	if got, want := migrationFilenames(pending), []string{"0003_orders.sql", migrations[2].Filename}; !slices.Equal(got, want) {
+		t.Errorf("PendingAfter() = %q, want %q", got, want)
+	}
+
+	fsys["migrations/0902_clash.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+	if _, err := LoadMigrations(fsys, "migrations"); err == nil || !strings.Contains(err.Error(), "has the same version as 0902_clash.sql") {
+		t.Errorf("LoadMigrations() error = %v, want a version clash", err)
+	}
+}
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/cortea-ai/pg-migrant/internal/config"
+	"github.com/cortea-ai/pg-migrant/internal/db"
+	"github.com/cortea-ai/pg-migrant/internal/diffutils"
+	"github.com/cortea-ai/pg-migrant/internal/notify"
+)
+
+// notifier posts the events of apply to the webhooks of the notify block of the env. Failing to notify
+// is logged, as it must not fail the run.
+type notifier struct {
+	n      *notify.Notifier
+	conf   *config.Config
+	logger *slog.Logger
+}
+
+func newNotifier(conf *config.Config, logger *slog.Logger) *notifier {
+	webhooks := conf.GetNotifyConfig().NotifyWebhooks()
+	if len(webhooks) == 0 {
+		return &notifier{conf: conf, logger: logger}
+	}
+	n, err := notify.New(http.DefaultClient, webhooks)
+	if err != nil {
+		logger.Error("notifications disabled", "error", err)
+	}
+	return &notifier{n: n, conf: conf, logger: logger}
+}
+
+func (n *notifier) enabled() bool {
+	return n.n != nil
+}
+
+func (n *notifier) notify(ctx context.Context, e notify.Event) {
+	if !n.enabled() {
+		return
+	}
+	e.Env = n.conf.SelectedEnv.Name
+	e.Error = config.Redact(e.Error)
+	// Notify even when the run was interrupted, to report it.
+	if err := n.n.Notify(context.WithoutCancel(ctx), e); err != nil {
+		n.logger.Warn("notifying failed", "event", e.Event, "error", err)
+		return
+	}
+	n.logger.Debug("notified", "event", e.Event)
+}
+
+// migrationEvent returns an event of the migrations of a run, with the hazards annotated in them.
+func migrationEvent(event, fromVersion string, migrations []Migration) notify.Event {
+	e := notify.Event{Event: event, FromVersion: fromVersion, ToVersion: fromVersion}
+	for _, m := range migrations {
+		if m.Repeatable {
+			e.Versions = append(e.Versions, m.Filename)
+		} else {
+			e.Versions = append(e.Versions, m.Version)
+			e.ToVersion = m.Version
+		}
+		e.Hazards = append(e.Hazards, diffutils.HazardsFromSQL(m.Content)...)
+	}
+	return e
+}
+
+// detectDrift returns the applied migrations whose content changed since, and, if the notify block
+// enables drift_check, the statements and hazards of the plan turning the schema of the database into
+// the one of the schema files.
+func detectDrift(ctx context.Context, conn *db.Conn, conf *config.Config, logger *slog.Logger) (drift, hazards []string, err error) {
+	history, err := conn.History(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading migration history: %w", err)
+	}
+	migrations, err := LoadMigrations(conf.GetFS(), conf.GetMigrationDir())
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, version := range modifiedVersions(history, migrations) {
+		drift = append(drift, fmt.Sprintf("migration %s changed since it was applied", version))
+	}
+	if !conf.GetNotifyConfig().DriftCheck || len(conf.GetSchemaFiles()) == 0 {
+		return drift, nil, nil
+	}
+	plan, err := GeneratePlan(ctx, conf, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, stmt := range plan.Statements {
+		drift = append(drift, "schema differs: "+FirstLine(stmt.DDL))
+		for _, h := range stmt.Hazards {
+			hazards = append(hazards, h.Type+": "+h.Message)
+		}
+	}
+	return drift, hazards, nil
+}
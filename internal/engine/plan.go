@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"github.com/cortea-ai/pg-migrant/internal/config"
+	"github.com/cortea-ai/pg-migrant/internal/diffutils"
+	"github.com/stripe/pg-schema-diff/pkg/diff"
+)
+
+// PlanOpts returns the pg-schema-diff options set by the diff block of the env.
+func PlanOpts(diffConf config.DiffConfig) []diff.PlanOpt {
+	var opts []diff.PlanOpt
+	if diffConf.GetDataPackNewTables() {
+		opts = append(opts, diff.WithDataPackNewTables())
+	}
+	if diffConf.GetRespectColumnOrder() {
+		opts = append(opts, diff.WithRespectColumnOrder())
+	}
+	if !diffConf.GetValidatePlan() {
+		opts = append(opts, diff.WithDoNotValidatePlan())
+	}
+	return opts
+}
+
+// AdaptPlan applies the options of the diff block that pg-schema-diff does not support itself.
+func AdaptPlan(diffConf config.DiffConfig, plan diff.Plan) diff.Plan {
+	if diffConf.GetNotValidForeignKeys() {
+		plan = diffutils.NotValidForeignKeys(plan)
+	}
+	if len(diffConf.IgnoreHazards) > 0 {
+		plan = diffutils.IgnoreHazards(plan, diffConf.IgnoreHazards)
+	}
+	return plan
+}
+
+// PlanToMigrations renders a plan as consecutive migrations. Each constraint validation added by
+// not_valid_foreign_keys gets a migration of its own, so that it does not hold the lock taken by the
+// statements before it. With concurrent_indexes, so does each concurrent index statement, running
+// outside of a transaction, so that the other statements still run in transactions.
+func PlanToMigrations(diffConf config.DiffConfig, plan diff.Plan) []string {
+	if !diffConf.GetConcurrentIndexes() {
+		plan = diffutils.StripConcurrently(plan)
+	}
+	var migrations []string
+	for _, part := range diffutils.SplitPlan(plan) {
+		if len(part.Statements) == 1 && diffutils.IsConcurrent(part.Statements[0]) {
+			migrations = append(migrations, diffutils.PlanToNonTransactionalS(part))
+		} else {
+			migrations = append(migrations, diffutils.PlanToPrettyS(part))
+		}
+	}
+	return migrations
+}
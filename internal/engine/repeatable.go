@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/cortea-ai/pg-migrant/internal/db"
+	"github.com/cortea-ai/pg-migrant/internal/fsutil"
+)
+
+// readRepeatables returns the .sql files of repeatableDir in fsys as migrations without a version,
+// ordered by filename. It returns nothing if repeatableDir is empty.
+func readRepeatables(fsys fs.FS, repeatableDir string) ([]Migration, error) {
+	if repeatableDir == "" {
+		return nil, nil
+	}
+	repeatableDir = fsutil.Clean(repeatableDir)
+	files, err := fs.ReadDir(fsys, repeatableDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repeatable migration directory: %w", err)
+	}
+	var repeatables []Migration
+	for _, file := range files {
+		if file.IsDir() || strings.ToLower(path.Ext(file.Name())) != ".sql" {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, path.Join(repeatableDir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read repeatable migration file %s: %w", file.Name(), err)
+		}
+		repeatables = append(repeatables, Migration{
+			Filename:   file.Name(),
+			Content:    string(content),
+			Repeatable: true,
+		})
+	}
+	return repeatables, nil
+}
+
+// PendingRepeatables returns the repeatable migrations of repeatableDir in fsys that were never applied,
+// or whose checksum changed since they were last applied.
+func PendingRepeatables(ctx context.Context, conn *db.Conn, fsys fs.FS, repeatableDir string) ([]Migration, error) {
+	repeatables, err := readRepeatables(fsys, repeatableDir)
+	if err != nil || len(repeatables) == 0 {
+		return nil, err
+	}
+	checksums, err := conn.RepeatableChecksums(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading repeatable migration checksums: %w", err)
+	}
+	var pending []Migration
+	for _, r := range repeatables {
+		if checksums[r.Filename] != db.Checksum(r.Content) {
+			pending = append(pending, r)
+		}
+	}
+	return pending, nil
+}
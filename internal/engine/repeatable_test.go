@@ -0,0 +1,32 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadRepeatables(t *testing.T) {
+	fsys := fstest.MapFS{
+		"repeatable/views.sql":     {Data: []byte("CREATE OR REPLACE VIEW v AS SELECT 1;")},
+		"repeatable/functions.SQL": {Data: []byte("CREATE OR REPLACE FUNCTION f() RETURNS int AS 'SELECT 1' LANGUAGE sql;")},
+		"repeatable/README.md":     {Data: []byte("# Repeatable migrations")},
+		"repeatable/old/views.sql": {Data: []byte("SELECT 1;")},
+	}
+	got, err := readRepeatables(fsys, "./repeatable")
+	if err != nil {
+		t.Fatalf("readRepeatables() error = %v", err)
+	}
+	if names, want := migrationFilenames(got), []string{"functions.SQL", "views.sql"}; !slices.Equal(names, want) {
+		t.Errorf("readRepeatables() = %q, want %q", names, want)
+	}
+	for _, r := range got {
+		if !r.Repeatable || r.Version != "" {
+			t.Errorf("readRepeatables() returned %+v, want a repeatable migration without a version", r)
+		}
+	}
+
+	if got, err := readRepeatables(fsys, ""); err != nil || got != nil {
+		t.Errorf("readRepeatables() without a directory = %v, %v, want nothing", got, err)
+	}
+}
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"context"
+	"slices"
+
+	"github.com/cortea-ai/pg-migrant/internal/config"
+	"github.com/cortea-ai/pg-migrant/internal/db"
+	"github.com/stripe/pg-schema-diff/pkg/schema"
+)
+
+// SchemaFilterOpts restricts schema introspection to the schemas managed by pg-migrant: the included
+// schemas if any, minus the excluded ones and pg-migrant's own schema.
+func SchemaFilterOpts(conf *config.Config) []schema.GetSchemaOpt {
+	opts := []schema.GetSchemaOpt{
+		schema.WithExcludeSchemas(append(conf.GetExcludeSchemas(), db.PGMigrantSchema)...),
+	}
+	if include := conf.GetIncludeSchemas(); len(include) > 0 {
+		opts = append(opts, schema.WithIncludeSchemas(include...))
+	}
+	return opts
+}
+
+// ManagedSchemas returns the schemas cleaned by pg-migrant: the included schemas or, without
+// include_schemas, public, minus the excluded ones and pg-migrant's own schema. Other schemas, such as
+// the ones of extensions, are never dropped unless included.
+func ManagedSchemas(conf *config.Config) []string {
+	schemas := conf.GetIncludeSchemas()
+	if len(schemas) == 0 {
+		schemas = []string{"public"}
+	}
+	return excludeSchemas(conf, schemas)
+}
+
+// IntrospectedSchemas returns the schemas of the database that diff compares: the included schemas or,
+// without include_schemas, every schema of the database, minus the excluded ones and pg-migrant's own
+// schema.
+func IntrospectedSchemas(ctx context.Context, conf *config.Config, conn *db.Conn) ([]string, error) {
+	schemas := conf.GetIncludeSchemas()
+	if len(schemas) == 0 {
+		var err error
+		if schemas, err = conn.ListSchemas(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return excludeSchemas(conf, schemas), nil
+}
+
+func excludeSchemas(conf *config.Config, schemas []string) []string {
+	var kept []string
+	for _, name := range schemas {
+		if name != db.PGMigrantSchema && !slices.Contains(conf.GetExcludeSchemas(), name) {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
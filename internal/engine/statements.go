@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// StatementResult is the outcome of a statement of a rehearsed or dry-run migration.
+type StatementResult struct {
+	// Line is 0 for Go migrations.
+	Line         int
+	SQL          string
+	Duration     time.Duration
+	RowsAffected int64
+	// Locks lists the relation locks first seen while the statement ran, e.g. "AccessExclusiveLock on public.users".
+	Locks []string
+	// Skipped is set for statements that were not executed.
+	Skipped bool
+	Err     error
+}
+
+// LogStatementResults logs the outcome of each statement of a migration, at debug level unless it failed.
+func LogStatementResults(logger *slog.Logger, m Migration, results []StatementResult) {
+	for i, r := range results {
+		attrs := []any{"version", m.Version, "file", m.Filename, "statement", i + 1, "line", r.Line, "duration", r.Duration, "rows", r.RowsAffected}
+		switch {
+		case r.Err != nil:
+			logger.Error("statement failed", append(attrs, "error", r.Err)...)
+		case r.Skipped:
+			logger.Debug("skipped statement", attrs...)
+		default:
+			logger.Debug("executed statement", attrs...)
+		}
+	}
+}
+
+// FirstLine returns the first line of a statement, truncated for tables.
+func FirstLine(stmt string) string {
+	const maxLen = 80
+	line, _, multiline := strings.Cut(stmt, "\n")
+	if len(line) > maxLen {
+		return line[:maxLen-3] + "..."
+	}
+	if multiline {
+		return line + " ..."
+	}
+	return line
+}
@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/cortea-ai/pg-migrant/internal/config"
+	"github.com/cortea-ai/pg-migrant/internal/db"
+	"github.com/cortea-ai/pg-migrant/internal/diffutils"
+	"github.com/cortea-ai/pg-migrant/internal/sqlparse"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stripe/pg-schema-diff/pkg/tempdb"
+)
+
+// NewTempDbFactory returns a factory creating temporary databases on the same
+// instance as the configured database. The caller must close it.
+func NewTempDbFactory(ctx context.Context, conf *config.Config) (tempdb.Factory, error) {
+	dbConfig, err := conf.GetDBConfig()
+	if err != nil {
+		return nil, err
+	}
+	return tempdb.NewOnInstanceFactory(ctx,
+		func(ctx context.Context, dbName string) (*sql.DB, error) {
+			connUrl, err := url.Parse(conf.GetDBUrl())
+			if err != nil {
+				return nil, fmt.Errorf("invalid connection string: %w", err)
+			}
+			connUrl.Path = "/" + dbName
+			conn, err := db.NewConn(ctx, connUrl.String())
+			if err != nil {
+				return nil, err
+			}
+			return conn.DB, nil
+		},
+		tempdb.WithRootDatabase(dbConfig.Database),
+	)
+}
+
+func CloseTempDbFactory(logger *slog.Logger, factory tempdb.Factory) {
+	if err := factory.Close(); err != nil {
+		logger.Warn("error shutting down temp db factory", "error", config.Redact(err.Error()))
+	}
+}
+
+func CloseTempDb(ctx context.Context, logger *slog.Logger, tempDb *tempdb.Database) {
+	if err := tempDb.Close(ctx); err != nil {
+		logger.Warn("error dropping temp database", "error", config.Redact(err.Error()))
+	}
+}
+
+// ReplayMigrations executes the given migrations, in order, against a temporary database. The
+// statements of non-transactional migrations are executed one by one, as apply does, and Go migrations
+// run in a transaction of their own.
+func ReplayMigrations(ctx context.Context, tempDb *tempdb.Database, migrations []Migration) error {
+	for _, m := range migrations {
+		if m.Func != nil {
+			if err := replayGoMigration(ctx, tempDb, m); err != nil {
+				return fmt.Errorf("replaying migration %s: %w", m.Filename, err)
+			}
+			continue
+		}
+		stmts := []string{m.Content}
+		if db.IsNonTransactional(m.Content) {
+			stmts = nil
+			for _, stmt := range sqlparse.Split(m.Content) {
+				stmts = append(stmts, stmt.SQL)
+			}
+		}
+		for _, stmt := range stmts {
+			if _, err := tempDb.ConnPool.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("replaying migration %s: %w", m.Filename, err)
+			}
+		}
+	}
+	return nil
+}
+
+func replayGoMigration(ctx context.Context, tempDb *tempdb.Database, m Migration) error {
+	conn, err := tempDb.ConnPool.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring connection: %w", err)
+	}
+	defer conn.Close()
+	return db.InPgxTx(ctx, conn, func(tx pgx.Tx) error {
+		return m.Func(ctx, tx)
+	})
+}
+
+// loadDDLs executes the statements of the schema files, in order, against a temporary database. A
+// failure is reported with the file and line of the failing statement.
+func loadDDLs(ctx context.Context, tempDb *tempdb.Database, ddls []diffutils.DDL) error {
+	for _, ddl := range ddls {
+		if _, err := tempDb.ConnPool.ExecContext(ctx, ddl.SQL); err != nil {
+			location := ddl.Location()
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Position > 0 {
+				// Position counts characters from the start of the statement.
+				runes := []rune(ddl.SQL)
+				if int(pgErr.Position) <= len(runes) {
+					line := ddl.Line + strings.Count(string(runes[:pgErr.Position-1]), "\n")
+					location = fmt.Sprintf("%s:%d", ddl.File, line)
+				}
+			}
+			return fmt.Errorf("%s: %w\n\n%s", location, err, statementExcerpt(ddl.SQL))
+		}
+	}
+	return nil
+}
+
+// statementExcerpt returns the first lines of a statement, indented, for error messages.
+func statementExcerpt(stmt string) string {
+	const maxLines = 10
+	lines := strings.Split(stmt, "\n")
+	if len(lines) > maxLines {
+		lines = append(lines[:maxLines], "...")
+	}
+	return "  " + strings.Join(lines, "\n  ")
+}
@@ -0,0 +1,37 @@
+// Package fsutil provides the file system migrations and schema files are read from.
+package fsutil
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// OS reads files of the operating system by the paths written in the config file, relative to the
+// working directory or absolute. Unlike os.DirFS, it accepts paths that are not valid fs.FS paths.
+var OS fs.FS = osFS{}
+
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(filepath.FromSlash(name))
+}
+
+func (osFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.FromSlash(name))
+}
+
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(filepath.FromSlash(name))
+}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(filepath.FromSlash(name))
+}
+
+// Clean turns a path written in the config file, such as "./migrations", into a slash-separated path
+// that fs.FS implementations other than OS, such as embed.FS, accept when it is relative.
+func Clean(name string) string {
+	return path.Clean(filepath.ToSlash(name))
+}
@@ -0,0 +1,194 @@
+// Package metrics records the metrics of migration runs and exports them in the Prometheus text
+// exposition format, to a file for the textfile collector of the node exporter or to a Pushgateway.
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metrics of migration runs.
+const (
+	MigrationsApplied = "pgmigrant_migrations_applied_total"
+	MigrationFailures = "pgmigrant_migration_failures_total"
+	MigrationDuration = "pgmigrant_migration_duration_seconds"
+	StatementDuration = "pgmigrant_statement_duration_seconds"
+	LockWait          = "pgmigrant_lock_wait_seconds"
+	PendingMigrations = "pgmigrant_pending_migrations"
+	AppliedMigrations = "pgmigrant_applied_migrations"
+	FailedMigrations  = "pgmigrant_failed_migrations"
+	CurrentVersion    = "pgmigrant_current_version_info"
+	LastRun           = "pgmigrant_last_run_timestamp_seconds"
+)
+
+var help = map[string]string{
+	MigrationsApplied: "Migrations applied by the run.",
+	MigrationFailures: "Migrations that failed during the run.",
+	MigrationDuration: "Duration of each migration applied by the run.",
+	StatementDuration: "Duration of each statement of the migrations applied by the run.",
+	LockWait:          "Time spent waiting for the migration lock.",
+	PendingMigrations: "Migrations pending on the database.",
+	AppliedMigrations: "Migrations recorded as applied in the database.",
+	FailedMigrations:  "Migrations recorded as failed in the database.",
+	CurrentVersion:    "Current migration version of the database, as a label.",
+	LastRun:           "Unix time at which the metrics were recorded.",
+}
+
+var counters = map[string]bool{
+	MigrationsApplied: true,
+	MigrationFailures: true,
+}
+
+// Registry holds the samples of a run. Its methods are safe for concurrent use.
+type Registry struct {
+	mu     sync.Mutex
+	labels []string
+	// samples maps metric names to the values of each label set, keyed by their rendering.
+	samples map[string]map[string]float64
+}
+
+// New returns an empty registry adding labels, given as name, value pairs, to every sample.
+func New(labels ...string) *Registry {
+	return &Registry{labels: labels, samples: make(map[string]map[string]float64)}
+}
+
+// Set sets the sample of name with labels, given as name, value pairs.
+func (r *Registry) Set(name string, value float64, labels ...string) {
+	r.update(name, labels, func(float64) float64 { return value })
+}
+
+// Add adds delta to the sample of name with labels, given as name, value pairs.
+func (r *Registry) Add(name string, delta float64, labels ...string) {
+	r.update(name, labels, func(v float64) float64 { return v + delta })
+}
+
+func (r *Registry) update(name string, labels []string, fn func(float64) float64) {
+	key := formatLabels(append(append([]string(nil), r.labels...), labels...))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.samples[name] == nil {
+		r.samples[name] = make(map[string]float64)
+	}
+	r.samples[name][key] = fn(r.samples[name][key])
+}
+
+// WriteTo writes the samples in the Prometheus text exposition format, sorted by metric and labels.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.samples))
+	for name := range r.samples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	for _, name := range names {
+		typ := "gauge"
+		if counters[name] {
+			typ = "counter"
+		}
+		if h, ok := help[name]; ok {
+			fmt.Fprintf(&buf, "# HELP %s %s\n", name, h)
+		}
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", name, typ)
+		keys := make([]string, 0, len(r.samples[name]))
+		for key := range r.samples[name] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(&buf, "%s%s %s\n", name, key, formatValue(r.samples[name][key]))
+		}
+	}
+	return buf.WriteTo(w)
+}
+
+// WriteTextfile writes the samples to path, atomically, so that the textfile collector never reads a
+// partial file. The collector only reads files ending in .prom.
+func (r *Registry) WriteTextfile(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".pgmigrant-metrics-*")
+	if err != nil {
+		return fmt.Errorf("writing metrics file: %w", err)
+	}
+	defer os.Remove(f.Name()) // No-op once renamed
+	if _, err := r.WriteTo(f); err != nil {
+		f.Close()
+		return fmt.Errorf("writing metrics file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing metrics file: %w", err)
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return fmt.Errorf("writing metrics file: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("writing metrics file: %w", err)
+	}
+	return nil
+}
+
+// Push replaces the metrics of job, grouped by the grouping labels given as name, value pairs, on the
+// Pushgateway at baseURL.
+func (r *Registry) Push(ctx context.Context, client *http.Client, baseURL, job string, grouping ...string) error {
+	target := strings.TrimSuffix(baseURL, "/") + "/metrics/job/" + url.PathEscape(job)
+	for i := 0; i+1 < len(grouping); i += 2 {
+		target += "/" + url.PathEscape(grouping[i]) + "/" + url.PathEscape(grouping[i+1])
+	}
+	var body bytes.Buffer
+	if _, err := r.WriteTo(&body); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, &body)
+	if err != nil {
+		return fmt.Errorf("pushing metrics: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("pushing metrics: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("pushing metrics: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels renders label pairs as {a="1",b="2"}, sorted by name, or as nothing without labels.
+func formatLabels(labels []string) string {
+	if len(labels) < 2 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+`="`+labelEscaper.Replace(labels[i+1])+`"`)
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,60 @@
+// Package migrate holds the migrations written in Go, registered through pkg/migrant.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Func is a migration written in Go. It runs in the transaction that records it as applied, so that a
+// failing migration leaves no trace.
+type Func func(ctx context.Context, tx pgx.Tx) error
+
+// GoMigration is a registered Go migration.
+type GoMigration struct {
+	Version string
+	// Name is the name of the function, e.g. "main.backfillDisplayNames".
+	Name string
+	Func Func
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]GoMigration)
+)
+
+// Register registers a Go migration under version, which shares the numbering of the SQL migrations. It
+// panics if fn is nil or version is already registered.
+func Register(version string, fn Func) {
+	if fn == nil {
+		panic(fmt.Sprintf("migrant: Register of migration %s with a nil function", version))
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[version]; ok {
+		panic(fmt.Sprintf("migrant: Register called twice for migration %s", version))
+	}
+	registry[version] = GoMigration{
+		Version: version,
+		Name:    runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name(),
+		Func:    fn,
+	}
+}
+
+// Registered returns the registered Go migrations, ordered by version.
+func Registered() []GoMigration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	migrations := make([]GoMigration, 0, len(registry))
+	for _, m := range registry {
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations
+}
@@ -0,0 +1,167 @@
+// Package notify posts the events of migration runs to webhooks, as JSON rendered from templates. The
+// default template is compatible with Slack incoming webhooks.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Events of migration runs.
+const (
+	EventStart   = "start"
+	EventSuccess = "success"
+	EventFailure = "failure"
+	EventDrift   = "drift"
+)
+
+// Events lists the events webhooks can subscribe to.
+var Events = []string{EventStart, EventSuccess, EventFailure, EventDrift}
+
+// requestTimeout bounds a webhook request, which should not hold up a deploy.
+const requestTimeout = 10 * time.Second
+
+// Event is an event of a migration run, as passed to the templates.
+type Event struct {
+	// Event is one of the Event* constants.
+	Event string
+	Env   string
+	// FromVersion is the version of the database when the run started.
+	FromVersion string
+	// ToVersion is the version the run applies up to, or the version of the failing migration.
+	ToVersion string
+	// Versions lists the versions, or the filenames of repeatable migrations, the run applies. On failure,
+	// the ones applied before the failure.
+	Versions []string
+	Duration time.Duration
+	// Hazards lists the hazards of the migrations, as annotated by diff, e.g. "INDEX_BUILD: ...".
+	Hazards []string
+	Error   string
+	// Drift lists the applied migrations whose content changed, and the statements turning the schema of
+	// the database into the one of the schema files.
+	Drift []string
+}
+
+// Webhook is an endpoint events are posted to.
+type Webhook struct {
+	Name string
+	URL  string
+	// Events lists the events posted, all of them if empty.
+	Events []string
+	// Template renders the JSON payload from an Event. DefaultTemplate is used if empty.
+	Template string
+	Headers  map[string]string
+}
+
+// DefaultTemplate renders a Slack-compatible message.
+const DefaultTemplate = `{{- $text := "" -}}
+{{- if eq .Event "start" -}}
+  {{- $text = printf ":rocket: Applying %d migrations to *%s* (%s → %s)" (len .Versions) .Env (or .FromVersion "none") .ToVersion -}}
+{{- else if eq .Event "success" -}}
+  {{- $text = printf ":white_check_mark: Applied %d migrations to *%s* in %s (now at %s)" (len .Versions) .Env .Duration (or .ToVersion "none") -}}
+{{- else if eq .Event "failure" -}}
+  {{- $text = printf ":x: Migrations failed on *%s* after %s at %s: %s" .Env .Duration (or .ToVersion "-") .Error -}}
+{{- else if eq .Event "drift" -}}
+  {{- $text = printf ":warning: Drift detected on *%s*:\n%s" .Env (join .Drift "\n") -}}
+{{- end -}}
+{{- if .Hazards -}}
+  {{- $text = printf "%s\nHazards:\n%s" $text (join .Hazards "\n") -}}
+{{- end -}}
+{"text": {{ json $text }}}`
+
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"join": strings.Join,
+}
+
+// ParseTemplate parses a payload template. Templates can use the json function, which renders a value
+// as JSON, and the join function of the strings package.
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("payload").Funcs(funcs).Parse(text)
+}
+
+// Notifier posts events to webhooks.
+type Notifier struct {
+	client    *http.Client
+	webhooks  []Webhook
+	templates []*template.Template
+}
+
+// New returns a notifier posting to webhooks with client.
+func New(client *http.Client, webhooks []Webhook) (*Notifier, error) {
+	n := &Notifier{client: client, webhooks: webhooks}
+	for _, w := range webhooks {
+		text := w.Template
+		if text == "" {
+			text = DefaultTemplate
+		}
+		tmpl, err := ParseTemplate(text)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %q: %w", w.Name, err)
+		}
+		n.templates = append(n.templates, tmpl)
+	}
+	return n, nil
+}
+
+// Notify posts e to the webhooks subscribed to it. Every webhook is tried; their errors are joined.
+func (n *Notifier) Notify(ctx context.Context, e Event) error {
+	var errs []error
+	for i, w := range n.webhooks {
+		if len(w.Events) > 0 && !slices.Contains(w.Events, e.Event) {
+			continue
+		}
+		if err := n.post(ctx, w, n.templates[i], e); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %q: %w", w.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) post(ctx context.Context, w Webhook, tmpl *template.Template, e Event) error {
+	var payload bytes.Buffer
+	if err := tmpl.Execute(&payload, e); err != nil {
+		return err
+	}
+	if !json.Valid(payload.Bytes()) {
+		return fmt.Errorf("template rendered invalid JSON for the %s event", e.Event)
+	}
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, &payload)
+	if err != nil {
+		return errors.New("invalid url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// The URL of a webhook is often a secret: leave it out of the error.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
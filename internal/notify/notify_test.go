@@ -0,0 +1,215 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// request is a request received by a recorder.
+type request struct {
+	path   string
+	header http.Header
+	body   string
+}
+
+// recorder is a webhook endpoint recording the requests it receives.
+type recorder struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []request
+}
+
+func newRecorder(t *testing.T, status int) *recorder {
+	r := &recorder{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.requests = append(r.requests, request{path: req.URL.Path, header: req.Header, body: string(body)})
+		r.mu.Unlock()
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			io.WriteString(w, "invalid_token\n")
+		}
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *recorder) paths() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var paths []string
+	for _, req := range r.requests {
+		paths = append(paths, req.path)
+	}
+	return paths
+}
+
+func TestDefaultTemplate(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{
+			name:  "start",
+			event: Event{Event: EventStart, Env: "prod", FromVersion: "0041", ToVersion: "0043", Versions: []string{"0042", "0043"}},
+			want:  ":rocket: Applying 2 migrations to *prod* (0041 → 0043)",
+		},
+		{
+			name:  "start on an empty database",
+			event: Event{Event: EventStart, Env: "prod", ToVersion: "0001", Versions: []string{"0001"}},
+			want:  ":rocket: Applying 1 migrations to *prod* (none → 0001)",
+		},
+		{
+			name: "start with hazards",
+			event: Event{Event: EventStart, Env: "prod", FromVersion: "0041", ToVersion: "0042", Versions: []string{"0042"},
+				Hazards: []string{"INDEX_BUILD: builds an index", `DELETES_DATA: drops column "name"`}},
+			want: ":rocket: Applying 1 migrations to *prod* (0041 → 0042)\nHazards:\nINDEX_BUILD: builds an index\nDELETES_DATA: drops column \"name\"",
+		},
+		{
+			name:  "success",
+			event: Event{Event: EventSuccess, Env: "prod", FromVersion: "0041", ToVersion: "0043", Versions: []string{"0042", "0043"}, Duration: 90 * time.Second},
+			want:  ":white_check_mark: Applied 2 migrations to *prod* in 1m30s (now at 0043)",
+		},
+		{
+			name:  "failure",
+			event: Event{Event: EventFailure, Env: "prod", ToVersion: "0042", Duration: 1500 * time.Millisecond, Error: `relation "users" does not exist`},
+			want:  `:x: Migrations failed on *prod* after 1.5s at 0042: relation "users" does not exist`,
+		},
+		{
+			name:  "drift",
+			event: Event{Event: EventDrift, Env: "prod", Drift: []string{"migration 0042 changed since it was applied", "DROP INDEX idx"}},
+			want:  ":warning: Drift detected on *prod*:\nmigration 0042 changed since it was applied\nDROP INDEX idx",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newRecorder(t, http.StatusOK)
+			n, err := New(server.Client(), []Webhook{{Name: "slack", URL: server.URL}})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if err := n.Notify(context.Background(), tt.event); err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+			if len(server.requests) != 1 {
+				t.Fatalf("webhook received %d requests, want 1", len(server.requests))
+			}
+			req := server.requests[0]
+			if got := req.header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			var payload map[string]string
+			if err := json.Unmarshal([]byte(req.body), &payload); err != nil {
+				t.Fatalf("payload %q is not a JSON object of strings: %v", req.body, err)
+			}
+			if len(payload) != 1 || payload["text"] != tt.want {
+				t.Errorf("payload = %q, want text %q", payload, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotifyTemplateAndHeaders(t *testing.T) {
+	server := newRecorder(t, http.StatusOK)
+	n, err := New(server.Client(), []Webhook{{
+		Name:     "pagerduty",
+		URL:      server.URL + "/events",
+		Template: `{"summary": {{ json .Error }}, "versions": {{ json .Versions }}, "env": "{{ .Env }}"}`,
+		Headers:  map[string]string{"Authorization": "Token token=secret", "Content-Type": "application/vnd.pagerduty+json"},
+	}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	event := Event{Event: EventFailure, Env: "prod", Versions: []string{"0042"}, Error: `syntax error at "CRATE"`}
+	if err := n.Notify(context.Background(), event); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	req := server.requests[0]
+	if want := `{"summary": "syntax error at \"CRATE\"", "versions": ["0042"], "env": "prod"}`; req.body != want {
+		t.Errorf("payload = %s, want %s", req.body, want)
+	}
+	if req.path != "/events" {
+		t.Errorf("path = %q, want /events", req.path)
+	}
+	if got := req.header.Get("Authorization"); got != "Token token=secret" {
+		t.Errorf("Authorization = %q, want the configured header", got)
+	}
+	if got := req.header.Get("Content-Type"); got != "application/vnd.pagerduty+json" {
+		t.Errorf("Content-Type = %q, want the configured header to override the default", got)
+	}
+}
+
+func TestNotifyEvents(t *testing.T) {
+	server := newRecorder(t, http.StatusOK)
+	n, err := New(server.Client(), []Webhook{
+		{Name: "all", URL: server.URL + "/all"},
+		{Name: "failures", URL: server.URL + "/failures", Events: []string{EventFailure}},
+		{Name: "deploys", URL: server.URL + "/deploys", Events: []string{EventStart, EventSuccess}},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	for _, event := range Events {
+		if err := n.Notify(context.Background(), Event{Event: event, Env: "prod"}); err != nil {
+			t.Fatalf("Notify(%s) error = %v", event, err)
+		}
+	}
+	want := []string{"/all", "/deploys", "/all", "/deploys", "/all", "/failures", "/all"}
+	if got := server.paths(); !slices.Equal(got, want) {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+}
+
+func TestNotifyErrors(t *testing.T) {
+	server := newRecorder(t, http.StatusForbidden)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	ok := newRecorder(t, http.StatusOK)
+
+	n, err := New(server.Client(), []Webhook{
+		{Name: "forbidden", URL: server.URL},
+		{Name: "down", URL: closed.URL + "/hooks/secret-token"},
+		{Name: "invalid", URL: ok.URL, Template: `{"text": {{ .Env }}}`},
+		{Name: "ok", URL: ok.URL + "/ok"},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	err = n.Notify(context.Background(), Event{Event: EventSuccess, Env: "prod"})
+	if err == nil {
+		t.Fatalf("Notify() succeeded, want the errors of the failing webhooks")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		`webhook "forbidden": 403 Forbidden: invalid_token`,
+		`webhook "down": `,
+		`webhook "invalid": template rendered invalid JSON for the success event`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Notify() error = %q, want it to contain %q", msg, want)
+		}
+	}
+	if strings.Contains(msg, "secret-token") {
+		t.Errorf("Notify() error = %q, want the webhook URL left out", msg)
+	}
+	if got := ok.paths(); !slices.Equal(got, []string{"/ok"}) {
+		t.Errorf("requests = %q, want the webhooks after a failing one to be posted to", got)
+	}
+}
+
+func TestNewInvalidTemplate(t *testing.T) {
+	_, err := New(http.DefaultClient, []Webhook{{Name: "slack", URL: "https://hooks.example.com", Template: "{{ .Event "}})
+	if err == nil || !strings.HasPrefix(err.Error(), `webhook "slack": `) {
+		t.Errorf("New() error = %v, want the template error of the webhook", err)
+	}
+}
@@ -0,0 +1,167 @@
+package sqlparse
+
+import (
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	// tokWord is a keyword or an unquoted identifier.
+	tokWord
+	// tokQuotedIdent is a double-quoted identifier. Its text is unquoted.
+	tokQuotedIdent
+	// tokString is a string literal, including escape and dollar-quoted strings. Its text is verbatim.
+	tokString
+	tokNumber
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// start and end are the byte offsets of the token in the source.
+	start, end int
+}
+
+func (t token) is(keyword string) bool {
+	return t.kind == tokWord && strings.EqualFold(t.text, keyword)
+}
+
+// lex returns the token starting at or after pos, skipping whitespace and comments. An unterminated
+// quoted token extends to the end of src.
+func lex(src string, pos int) token {
+	pos = skipSpaceAndComments(src, pos)
+	if pos >= len(src) {
+		return token{kind: tokEOF, start: len(src), end: len(src)}
+	}
+	c := src[pos]
+	switch {
+	case c == '"':
+		var sb strings.Builder
+		for i := pos + 1; i < len(src); i++ {
+			if src[i] == '"' {
+				if i+1 < len(src) && src[i+1] == '"' {
+					sb.WriteByte('"')
+					i++
+					continue
+				}
+				return token{kind: tokQuotedIdent, text: sb.String(), start: pos, end: i + 1}
+			}
+			sb.WriteByte(src[i])
+		}
+		return token{kind: tokQuotedIdent, text: sb.String(), start: pos, end: len(src)}
+	case c == '\'':
+		end := stringEnd(src, pos+1, false)
+		return token{kind: tokString, text: src[pos:end], start: pos, end: end}
+	case (c == 'E' || c == 'e') && pos+1 < len(src) && src[pos+1] == '\'':
+		end := stringEnd(src, pos+2, true)
+		return token{kind: tokString, text: src[pos:end], start: pos, end: end}
+	case c == '$':
+		if tag, ok := dollarTag(src, pos); ok {
+			closing := strings.Index(src[pos+len(tag):], tag)
+			end := len(src)
+			if closing >= 0 {
+				end = pos + len(tag) + closing + len(tag)
+			}
+			return token{kind: tokString, text: src[pos:end], start: pos, end: end}
+		}
+		return token{kind: tokPunct, text: "$", start: pos, end: pos + 1}
+	case isIdentStart(c):
+		end := pos + 1
+		for end < len(src) && isIdentPart(src[end]) {
+			end++
+		}
+		return token{kind: tokWord, text: src[pos:end], start: pos, end: end}
+	case c >= '0' && c <= '9':
+		end := pos + 1
+		for end < len(src) && (src[end] >= '0' && src[end] <= '9' || src[end] == '.') {
+			end++
+		}
+		return token{kind: tokNumber, text: src[pos:end], start: pos, end: end}
+	default:
+		return token{kind: tokPunct, text: src[pos : pos+1], start: pos, end: pos + 1}
+	}
+}
+
+// stringEnd returns the offset after the quote closing a string literal whose content starts at pos.
+func stringEnd(src string, pos int, backslashEscapes bool) int {
+	for i := pos; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			if backslashEscapes {
+				i++
+			}
+		case '\'':
+			if i+1 < len(src) && src[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(src)
+}
+
+// dollarTag returns the opening tag of a dollar-quoted string starting at pos, e.g. $$ or $body$.
+func dollarTag(src string, pos int) (string, bool) {
+	i := pos + 1
+	if i < len(src) && src[i] != '$' {
+		// Positional parameters such as $1 are not dollar quotes.
+		if !isIdentStart(src[i]) {
+			return "", false
+		}
+		for i < len(src) && isIdentPart(src[i]) && src[i] != '$' {
+			i++
+		}
+	}
+	if i < len(src) && src[i] == '$' {
+		return src[pos : i+1], true
+	}
+	return "", false
+}
+
+// skipSpaceAndComments returns the offset of the first character at or after pos that is neither
+// whitespace nor part of a comment. Block comments may be nested, as in Postgres.
+func skipSpaceAndComments(src string, pos int) int {
+	for pos < len(src) {
+		switch {
+		case src[pos] == ' ' || src[pos] == '\t' || src[pos] == '\n' || src[pos] == '\r' || src[pos] == '\f':
+			pos++
+		case strings.HasPrefix(src[pos:], "--"):
+			nl := strings.IndexByte(src[pos:], '\n')
+			if nl < 0 {
+				return len(src)
+			}
+			pos += nl + 1
+		case strings.HasPrefix(src[pos:], "/*"):
+			depth := 0
+			for pos < len(src) {
+				if strings.HasPrefix(src[pos:], "/*") {
+					depth++
+					pos += 2
+				} else if strings.HasPrefix(src[pos:], "*/") {
+					depth--
+					pos += 2
+					if depth == 0 {
+						break
+					}
+				} else {
+					pos++
+				}
+			}
+		default:
+			return pos
+		}
+	}
+	return pos
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9') || c == '$'
+}
@@ -0,0 +1,98 @@
+package sqlparse
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []token
+	}{
+		{
+			name: "words, numbers and punctuation",
+			src:  "SELECT 1.5, x::int;",
+			want: []token{
+				{kind: tokWord, text: "SELECT"}, {kind: tokNumber, text: "1.5"}, {kind: tokPunct, text: ","},
+				{kind: tokWord, text: "x"}, {kind: tokPunct, text: ":"}, {kind: tokPunct, text: ":"},
+				{kind: tokWord, text: "int"}, {kind: tokPunct, text: ";"},
+			},
+		},
+		{
+			name: "string with doubled quote",
+			src:  `'it''s; -- not a comment'`,
+			want: []token{{kind: tokString, text: `'it''s; -- not a comment'`}},
+		},
+		{
+			name: "escape string",
+			src:  `E'it\'s; \\' x`,
+			want: []token{{kind: tokString, text: `E'it\'s; \\'`}, {kind: tokWord, text: "x"}},
+		},
+		{
+			name: "backslash does not escape in standard strings",
+			src:  `'a\' x`,
+			want: []token{{kind: tokString, text: `'a\'`}, {kind: tokWord, text: "x"}},
+		},
+		{
+			name: "quoted identifier with doubled quote",
+			src:  `"My ""Table"";"`,
+			want: []token{{kind: tokQuotedIdent, text: `My "Table";`}},
+		},
+		{
+			name: "dollar quotes",
+			src:  "$$ a; 'b $$ c",
+			want: []token{{kind: tokString, text: "$$ a; 'b $$"}, {kind: tokWord, text: "c"}},
+		},
+		{
+			name: "tagged dollar quotes containing other tags",
+			src:  "$fn$ SELECT $$;$$ $fn$",
+			want: []token{{kind: tokString, text: "$fn$ SELECT $$;$$ $fn$"}},
+		},
+		{
+			name: "positional parameter",
+			src:  "$1",
+			want: []token{{kind: tokPunct, text: "$"}, {kind: tokNumber, text: "1"}},
+		},
+		{
+			name: "line comments",
+			src:  "a -- b; c\nd",
+			want: []token{{kind: tokWord, text: "a"}, {kind: tokWord, text: "d"}},
+		},
+		{
+			name: "nested block comments",
+			src:  "a /* b /* c; */ d; */ e",
+			want: []token{{kind: tokWord, text: "a"}, {kind: tokWord, text: "e"}},
+		},
+		{
+			name: "unterminated string",
+			src:  "a 'b; c",
+			want: []token{{kind: tokWord, text: "a"}, {kind: tokString, text: "'b; c"}},
+		},
+		{
+			name: "unterminated comment",
+			src:  "a /* b /* c */",
+			want: []token{{kind: tokWord, text: "a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []token
+			for pos := 0; ; {
+				tok := lex(tt.src, pos)
+				if tok.kind == tokEOF {
+					break
+				}
+				if tt.src[tok.start:tok.end] == "" {
+					t.Fatalf("lex(%q, %d) returned an empty token", tt.src, pos)
+				}
+				pos = tok.end
+				got = append(got, token{kind: tok.kind, text: tok.text})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("lex(%q) = %v, want %v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
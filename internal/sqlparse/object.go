@@ -0,0 +1,413 @@
+package sqlparse
+
+import (
+	"strings"
+)
+
+// Object kinds returned by ParseObject.
+const (
+	KindSchema    = "schema"
+	KindExtension = "extension"
+	KindType      = "type"
+	KindTable     = "table"
+	KindSequence  = "sequence"
+	KindFunction  = "function"
+	KindView      = "view"
+	KindUnknown   = ""
+)
+
+const defaultSchema = "public"
+
+// Object identifies the database object a DDL statement creates or alters. Statements on objects that
+// belong to a table, such as indexes, triggers, policies and constraints, identify the table.
+type Object struct {
+	Kind   string
+	Schema string
+	Name   string
+}
+
+// ParseObject returns the object a single DDL statement creates or alters. Kind is KindUnknown if the
+// statement is not recognized. Unqualified names are assumed to be in the public schema.
+func ParseObject(stmt string) Object {
+	t := newTokenizer(stmt)
+	switch {
+	case t.accept("CREATE", "SCHEMA"):
+		t.accept("IF", "NOT", "EXISTS")
+		name := t.ident()
+		return Object{Kind: KindSchema, Schema: name, Name: name}
+	case t.accept("CREATE", "EXTENSION"):
+		t.accept("IF", "NOT", "EXISTS")
+		name := t.ident()
+		schema := defaultSchema
+		t.accept("WITH")
+		if t.accept("SCHEMA") {
+			schema = t.ident()
+		}
+		return Object{Kind: KindExtension, Schema: schema, Name: name}
+	case t.accept("CREATE", "TYPE"), t.accept("ALTER", "TYPE"), t.accept("CREATE", "DOMAIN"), t.accept("ALTER", "DOMAIN"):
+		return t.qualified(KindType)
+	case t.accept("CREATE"):
+		t.accept("OR", "REPLACE")
+		t.accept("UNIQUE")
+		t.accept("CONSTRAINT")
+		t.acceptAny("TEMP", "TEMPORARY", "UNLOGGED", "MATERIALIZED", "RECURSIVE")
+		switch {
+		case t.accept("TABLE"):
+			t.accept("IF", "NOT", "EXISTS")
+			return t.qualified(KindTable)
+		case t.accept("SEQUENCE"):
+			t.accept("IF", "NOT", "EXISTS")
+			return t.qualified(KindSequence)
+		case t.acceptAny("FUNCTION", "PROCEDURE"):
+			return t.qualified(KindFunction)
+		case t.accept("VIEW"):
+			return t.qualified(KindView)
+		case t.acceptAny("INDEX", "TRIGGER", "POLICY", "RULE"):
+			// The table follows the first ON keyword, e.g. CREATE INDEX name ON ONLY schema.table.
+			if !t.skipTo("ON") {
+				return Object{}
+			}
+			t.accept("ONLY")
+			return t.qualified(KindTable)
+		}
+	case t.accept("ALTER", "TABLE"):
+		t.accept("IF", "EXISTS")
+		t.accept("ONLY")
+		return t.qualified(KindTable)
+	case t.accept("ALTER", "SEQUENCE"):
+		t.accept("IF", "EXISTS")
+		seq := t.qualified(KindSequence)
+		// A sequence owned by a column belongs to the table, e.g. OWNED BY schema.table.column.
+		if t.skipTo("OWNED") && t.accept("BY") {
+			if parts := t.identChain(); len(parts) == 3 {
+				return Object{Kind: KindTable, Schema: parts[0], Name: parts[1]}
+			} else if len(parts) == 2 {
+				return Object{Kind: KindTable, Schema: defaultSchema, Name: parts[0]}
+			}
+		}
+		return seq
+	case t.accept("ALTER", "INDEX"):
+		// Indexes are named after their table only by convention, so keep them apart.
+		return Object{}
+	}
+	return Object{}
+}
+
+// ParseDefinition returns the object a single DDL statement creates, if it creates one. Statements
+// creating an index, trigger, policy or rule on a table do not define the table.
+func ParseDefinition(stmt string) (Object, bool) {
+	obj := ParseObject(stmt)
+	t := newTokenizer(stmt)
+	if obj.Kind == KindUnknown || !t.accept("CREATE") {
+		return Object{}, false
+	}
+	t.accept("OR", "REPLACE")
+	t.accept("UNIQUE")
+	t.accept("CONSTRAINT")
+	if t.acceptAny("INDEX", "TRIGGER", "POLICY", "RULE") {
+		return Object{}, false
+	}
+	return obj, true
+}
+
+// AddedConstraint returns the table and the name of the constraint an ALTER TABLE ... ADD CONSTRAINT
+// statement adds, as written in the statement, quotes included.
+func AddedConstraint(stmt string) (table, constraint string, ok bool) {
+	t := newTokenizer(stmt)
+	if !t.accept("ALTER", "TABLE") {
+		return "", "", false
+	}
+	t.accept("IF", "EXISTS")
+	t.accept("ONLY")
+	start := t.next().start
+	if len(t.identChain()) == 0 {
+		return "", "", false
+	}
+	table = stmt[start:t.pos]
+	if !t.accept("ADD", "CONSTRAINT") {
+		return "", "", false
+	}
+	start = t.next().start
+	if t.ident() == "" {
+		return "", "", false
+	}
+	return table, stmt[start:t.pos], true
+}
+
+// HasKeywords reports whether the keywords appear consecutively in stmt. Keywords inside comments,
+// string literals and quoted identifiers do not count.
+func HasKeywords(stmt string, keywords ...string) bool {
+	if len(keywords) == 0 {
+		return false
+	}
+	t := newTokenizer(stmt)
+	for t.skipTo(keywords[0]) {
+		if t.accept(keywords[1:]...) {
+			return true
+		}
+	}
+	return false
+}
+
+// dataKeywords start the statements that read or write rows rather than define the schema.
+var dataKeywords = []string{"INSERT", "UPDATE", "DELETE", "MERGE", "TRUNCATE", "COPY", "SELECT", "WITH", "VALUES", "CALL", "DO"}
+
+// IsDataStatement reports whether stmt reads or writes rows rather than defines the schema, e.g. an
+// INSERT seeding a table or a DO block backfilling a column.
+func IsDataStatement(stmt string) bool {
+	return isKeyword(newTokenizer(stmt).next(), dataKeywords)
+}
+
+// String returns the schema-qualified name of the object.
+func (o Object) String() string {
+	if o.Kind == KindSchema || o.Kind == KindUnknown {
+		return o.Name
+	}
+	return o.Schema + "." + o.Name
+}
+
+type tokenizer struct {
+	src string
+	pos int
+}
+
+func newTokenizer(src string) *tokenizer {
+	return &tokenizer{src: src}
+}
+
+// next returns the next token without consuming it.
+func (t *tokenizer) next() token {
+	return lex(t.src, t.pos)
+}
+
+// accept consumes the given keywords if they all come next.
+func (t *tokenizer) accept(keywords ...string) bool {
+	pos := t.pos
+	for _, kw := range keywords {
+		tok := t.next()
+		if !tok.is(kw) && !(tok.kind == tokPunct && tok.text == kw) {
+			t.pos = pos
+			return false
+		}
+		t.pos = tok.end
+	}
+	return true
+}
+
+// acceptAny consumes one of the given keywords if it comes next.
+func (t *tokenizer) acceptAny(keywords ...string) bool {
+	for _, kw := range keywords {
+		if t.accept(kw) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipTo consumes tokens up to and including the given keyword.
+func (t *tokenizer) skipTo(keyword string) bool {
+	for {
+		tok := t.next()
+		if tok.kind == tokEOF {
+			return false
+		}
+		t.pos = tok.end
+		if tok.is(keyword) {
+			return true
+		}
+	}
+}
+
+// ident consumes an identifier. Unquoted identifiers are folded to lower case, as Postgres does.
+func (t *tokenizer) ident() string {
+	tok := t.next()
+	switch tok.kind {
+	case tokQuotedIdent:
+		t.pos = tok.end
+		return tok.text
+	case tokWord:
+		t.pos = tok.end
+		return strings.ToLower(tok.text)
+	}
+	return ""
+}
+
+// identChain consumes a dot-separated chain of identifiers, e.g. schema.table.column.
+func (t *tokenizer) identChain() []string {
+	var parts []string
+	for {
+		part := t.ident()
+		if part == "" {
+			return parts
+		}
+		parts = append(parts, part)
+		if !t.accept(".") {
+			return parts
+		}
+	}
+}
+
+// qualified consumes a possibly schema-qualified name.
+func (t *tokenizer) qualified(kind string) Object {
+	name := t.ident()
+	if name == "" {
+		return Object{}
+	}
+	if t.accept(".") {
+		return Object{Kind: kind, Schema: name, Name: t.ident()}
+	}
+	return Object{Kind: kind, Schema: defaultSchema, Name: name}
+}
+
+// referenceKeywords are followed by the name of an object, e.g. REFERENCES users or ON TABLE users.
+var referenceKeywords = []string{
+	"FROM", "JOIN", "REFERENCES", "INTO", "UPDATE", "TABLE", "ONLY", "ON", "LIKE", "INHERITS", "OF",
+	"TYPE", "DOMAIN", "SEQUENCE", "VIEW", "FUNCTION", "PROCEDURE", "RETURNS", "SETOF", "SCHEMA",
+}
+
+// elementKeywords start the elements of a parenthesized list that are not a name followed by a type.
+var elementKeywords = []string{
+	"CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN", "EXCLUDE", "SELECT", "DISTINCT", "CASE", "NOT",
+}
+
+// argModes precede the names of function arguments.
+var argModes = []string{"IN", "OUT", "INOUT", "VARIADIC"}
+
+// groupKeywords are followed by a parenthesis without being function calls, e.g. CHECK (a > 0).
+var groupKeywords = []string{
+	"CHECK", "KEY", "UNIQUE", "EXCLUDE", "INCLUDE", "IN", "VALUES", "EXISTS", "AS", "USING", "WITH", "TO",
+	"AND", "OR", "NOT", "WHERE", "BY", "ANY", "ALL", "SOME", "ARRAY", "ROW", "OVER", "FILTER", "WHEN",
+	"THEN", "ELSE", "RETURN", "DEFAULT", "IS",
+}
+
+// References returns the objects stmt references, taken only from the positions of object names: after
+// keywords such as FROM, REFERENCES or TYPE, as types of columns and arguments, as called functions, in
+// casts and in regclass literals. Dollar-quoted bodies, such as function bodies, are searched too.
+// Kind is KindSchema for schemas, and KindUnknown otherwise. Unqualified names are assumed to be in
+// the public schema.
+func References(stmt string) []Object {
+	var refs []Object
+	addChain := func(chain []string) {
+		switch len(chain) {
+		case 0:
+		case 1:
+			refs = append(refs, Object{Schema: defaultSchema, Name: chain[0]})
+		default:
+			// A longer name may be schema.table.column: keep the schema and the table.
+			refs = append(refs, Object{Schema: chain[0], Name: chain[1]})
+		}
+	}
+	t := newTokenizer(stmt)
+	for {
+		tok := t.next()
+		switch {
+		case tok.kind == tokEOF:
+			return refs
+		case tok.is("SCHEMA"):
+			t.pos = tok.end
+			t.accept("IF", "NOT", "EXISTS")
+			if name := t.nameAt(); name != "" {
+				refs = append(refs, Object{Kind: KindSchema, Schema: name, Name: name})
+			}
+		case tok.is("ON") && t.isComparison():
+			// A join condition, e.g. ON a.id = b.a_id, names columns.
+			t.pos = tok.end
+			t.identChain()
+		case tok.is("ADD"):
+			// A column added by ALTER TABLE is a name followed by its type.
+			t.pos = tok.end
+			t.accept("COLUMN")
+			t.accept("IF", "NOT", "EXISTS")
+			if !t.nameNext() || isKeyword(t.next(), elementKeywords) {
+				continue
+			}
+			t.ident()
+			if t.nameNext() {
+				addChain(t.identChain())
+			}
+		case tok.is("INHERITS"):
+			t.pos = tok.end
+			if t.accept("(") {
+				for ok := true; ok; ok = t.accept(",") {
+					addChain(t.identChain())
+				}
+			}
+		case tok.kind == tokWord && isKeyword(tok, referenceKeywords):
+			t.pos = tok.end
+			t.accept("IF", "NOT", "EXISTS")
+			t.accept("IF", "EXISTS")
+			if t.nameNext() {
+				addChain(t.identChain())
+			}
+		case tok.kind == tokPunct && tok.text == ":" && lex(stmt, tok.end).text == ":":
+			t.pos = lex(stmt, tok.end).end
+			addChain(t.identChain())
+		case tok.kind == tokPunct && (tok.text == "(" || tok.text == ","):
+			t.pos = tok.end
+			// An element of a column or argument list is a name followed by its type.
+			if first := t.next(); first.kind == tokWord && isKeyword(first, argModes) {
+				t.pos = first.end
+			}
+			if !t.nameNext() || isKeyword(t.next(), elementKeywords) {
+				continue
+			}
+			t.ident()
+			if t.nameNext() {
+				addChain(t.identChain())
+			}
+		case tok.kind == tokWord && isKeyword(tok, groupKeywords):
+			t.pos = tok.end
+		case tok.kind == tokWord || tok.kind == tokQuotedIdent:
+			chain := t.identChain()
+			// A function call, e.g. audit.log(...).
+			if t.next().text == "(" && t.next().kind == tokPunct {
+				addChain(chain)
+			}
+		case tok.kind == tokString:
+			t.pos = tok.end
+			if tag, ok := dollarTag(tok.text, 0); ok && len(tok.text) >= 2*len(tag) {
+				refs = append(refs, References(tok.text[len(tag):len(tok.text)-len(tag)])...)
+			} else if t.accept(":", ":", "REGCLASS") && strings.HasPrefix(tok.text, "'") {
+				// A sequence or table given by name, e.g. nextval('users_id_seq'::regclass).
+				addChain(newTokenizer(strings.Trim(tok.text, "'")).identChain())
+			}
+		default:
+			t.pos = tok.end
+		}
+	}
+}
+
+// nameNext reports whether the next token may be a name, as opposed to a keyword starting a clause.
+func (t *tokenizer) nameNext() bool {
+	tok := t.next()
+	return tok.kind == tokQuotedIdent || tok.kind == tokWord && !isKeyword(tok, referenceKeywords)
+}
+
+// nameAt consumes the identifier coming next if it may be a name.
+func (t *tokenizer) nameAt() string {
+	if !t.nameNext() {
+		return ""
+	}
+	return t.ident()
+}
+
+// isComparison reports whether the ON keyword coming next starts a condition, such as a join condition,
+// rather than naming a table.
+func (t *tokenizer) isComparison() bool {
+	probe := *t
+	probe.pos = probe.next().end
+	if len(probe.identChain()) == 0 {
+		return false
+	}
+	tok := probe.next()
+	return tok.kind == tokPunct && strings.Contains("=<>!", tok.text)
+}
+
+func isKeyword(tok token, keywords []string) bool {
+	for _, kw := range keywords {
+		if tok.is(kw) {
+			return true
+		}
+	}
+	return false
+}
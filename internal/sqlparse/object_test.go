@@ -0,0 +1,216 @@
+package sqlparse
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestReferences(t *testing.T) {
+	tests := []struct {
+		name string
+		stmt string
+		want []string
+	}{
+		{
+			name: "foreign key",
+			stmt: "CREATE TABLE orders (id int, user_id int REFERENCES users (id))",
+			want: []string{"public.orders", "public.int", "public.int", "public.users"},
+		},
+		{
+			name: "column types",
+			stmt: `CREATE TABLE billing.invoices (id bigint, status billing.status NOT NULL, "Total" "Money")`,
+			want: []string{"billing.invoices", "public.bigint", "billing.status", "public.Money"},
+		},
+		{
+			name: "table constraints are not columns",
+			stmt: "CREATE TABLE t (a int, CONSTRAINT t_a_check CHECK (a > 0), PRIMARY KEY (a))",
+			want: []string{"public.t", "public.int"},
+		},
+		{
+			name: "called functions and casts",
+			stmt: "CREATE TABLE t (id uuid DEFAULT util.new_id(), at timestamptz DEFAULT now(), kind text DEFAULT 'a'::kinds)",
+			want: []string{"public.t", "public.uuid", "util.new_id", "public.timestamptz", "public.now", "public.text", "public.kinds"},
+		},
+		{
+			name: "regclass literal",
+			stmt: "ALTER TABLE users ALTER COLUMN id SET DEFAULT nextval('public.users_id_seq'::regclass)",
+			want: []string{"public.users", "public.nextval", "public.users_id_seq"},
+		},
+		{
+			name: "view with join condition",
+			stmt: "CREATE VIEW v AS SELECT a.id, b.name FROM accounts a JOIN owners b ON a.owner_id = b.id WHERE a.active",
+			want: []string{"public.v", "public.accounts", "public.owners"},
+		},
+		{
+			name: "index and trigger tables",
+			stmt: "CREATE TRIGGER audit AFTER UPDATE ON public.users FOR EACH ROW EXECUTE FUNCTION audit.log()",
+			want: []string{"public.users", "audit.log"},
+		},
+		{
+			name: "function arguments, result and body",
+			stmt: "CREATE FUNCTION f(IN u users, n int) RETURNS SETOF orders LANGUAGE sql AS $$ SELECT * FROM orders WHERE user_id = u.id $$",
+			want: []string{"public.f", "public.users", "public.int", "public.orders", "public.orders"},
+		},
+		{
+			name: "function returning a table",
+			stmt: "CREATE FUNCTION f() RETURNS TABLE (id int, s status) LANGUAGE sql AS 'SELECT 1'",
+			want: []string{"public.f", "public.int", "public.status"},
+		},
+		{
+			name: "inheritance and partitions",
+			stmt: "CREATE TABLE c (x int) INHERITS (a, s.b)",
+			want: []string{"public.c", "public.int", "public.a", "s.b"},
+		},
+		{
+			name: "partition of",
+			stmt: "CREATE TABLE events_2024 PARTITION OF events FOR VALUES FROM ('2024-01-01') TO ('2025-01-01')",
+			want: []string{"public.events_2024", "public.events"},
+		},
+		{
+			name: "schemas",
+			stmt: "GRANT USAGE ON SCHEMA billing TO app",
+			want: []string{"schema billing"},
+		},
+		{
+			name: "names in comments and strings are ignored",
+			stmt: "-- REFERENCES users\nCOMMENT ON TABLE t IS 'FROM users'",
+			want: []string{"public.t"},
+		},
+		{
+			name: "added constraint",
+			stmt: "ALTER TABLE orders ADD CONSTRAINT orders_user_fk FOREIGN KEY (user_id) REFERENCES users (id) NOT VALID",
+			want: []string{"public.orders", "public.users"},
+		},
+		{
+			name: "quoted identifiers keep their case",
+			stmt: `ALTER TABLE "Sales"."Orders" ADD COLUMN note "Sales"."Note"`,
+			want: []string{"Sales.Orders", "Sales.Note"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, ref := range References(tt.stmt) {
+				if ref.Kind == KindSchema {
+					got = append(got, "schema "+ref.Name)
+				} else {
+					got = append(got, ref.Schema+"."+ref.Name)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("References(%q) = %q, want %q", tt.stmt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseObject(t *testing.T) {
+	tests := []struct {
+		stmt       string
+		want       Object
+		definition bool
+	}{
+		{"CREATE SCHEMA IF NOT EXISTS billing", Object{KindSchema, "billing", "billing"}, true},
+		{"CREATE EXTENSION citext WITH SCHEMA ext", Object{KindExtension, "ext", "citext"}, true},
+		{"CREATE TYPE billing.status AS ENUM ('open')", Object{KindType, "billing", "status"}, true},
+		{"ALTER TYPE status ADD VALUE 'paid'", Object{KindType, "public", "status"}, false},
+		{`CREATE UNLOGGED TABLE IF NOT EXISTS "Sales"."Orders" (id int)`, Object{KindTable, "Sales", "Orders"}, true},
+		{"CREATE OR REPLACE FUNCTION f() RETURNS int", Object{KindFunction, "public", "f"}, true},
+		{"CREATE MATERIALIZED VIEW reports AS SELECT 1", Object{KindView, "public", "reports"}, true},
+		{"CREATE UNIQUE INDEX CONCURRENTLY users_email ON ONLY app.users (email)", Object{KindTable, "app", "users"}, false},
+		{"CREATE CONSTRAINT TRIGGER t AFTER INSERT ON users FOR EACH ROW EXECUTE FUNCTION f()", Object{KindTable, "public", "users"}, false},
+		{"ALTER TABLE IF EXISTS ONLY users ADD COLUMN a int", Object{KindTable, "public", "users"}, false},
+		{"ALTER SEQUENCE users_id_seq OWNED BY app.users.id", Object{KindTable, "app", "users"}, false},
+		{"ALTER SEQUENCE users_id_seq RESTART", Object{KindSequence, "public", "users_id_seq"}, false},
+		{"ALTER INDEX users_email RENAME TO users_email_key", Object{}, false},
+		{"INSERT INTO users VALUES (1)", Object{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stmt, func(t *testing.T) {
+			if got := ParseObject(tt.stmt); got != tt.want {
+				t.Errorf("ParseObject() = %+v, want %+v", got, tt.want)
+			}
+			if _, got := ParseDefinition(tt.stmt); got != tt.definition {
+				t.Errorf("ParseDefinition() ok = %v, want %v", got, tt.definition)
+			}
+		})
+	}
+}
+
+func TestAddedConstraint(t *testing.T) {
+	tests := []struct {
+		stmt       string
+		table      string
+		constraint string
+		ok         bool
+	}{
+		{"ALTER TABLE orders ADD CONSTRAINT orders_user_fk FOREIGN KEY (user_id) REFERENCES users (id) NOT VALID", "orders", "orders_user_fk", true},
+		{`ALTER TABLE IF EXISTS ONLY "App"."Orders" ADD CONSTRAINT "Orders_Check" CHECK (total > 0) NOT VALID`, `"App"."Orders"`, `"Orders_Check"`, true},
+		{"alter table orders add constraint c check (total > 0)", "orders", "c", true},
+		{"ALTER TABLE orders ADD COLUMN total int", "", "", false},
+		{"ALTER TABLE orders VALIDATE CONSTRAINT orders_user_fk", "", "", false},
+		{"CREATE TABLE orders (CONSTRAINT c CHECK (total > 0))", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stmt, func(t *testing.T) {
+			table, constraint, ok := AddedConstraint(tt.stmt)
+			if table != tt.table || constraint != tt.constraint || ok != tt.ok {
+				t.Errorf("AddedConstraint() = %q, %q, %v, want %q, %q, %v", table, constraint, ok, tt.table, tt.constraint, tt.ok)
+			}
+		})
+	}
+}
+
+func TestHasKeywords(t *testing.T) {
+	tests := []struct {
+		stmt     string
+		keywords []string
+		want     bool
+	}{
+		{"CREATE INDEX CONCURRENTLY i ON t (a)", []string{"CONCURRENTLY"}, true},
+		{"create index concurrently i on t (a)", []string{"CONCURRENTLY"}, true},
+		{"ALTER TABLE t VALIDATE CONSTRAINT c", []string{"VALIDATE", "CONSTRAINT"}, true},
+		{"ALTER TABLE t ADD CONSTRAINT c CHECK (a > 0) NOT VALID", []string{"NOT", "VALID"}, true},
+		{"ALTER TABLE t ALTER COLUMN a SET NOT NULL, VALIDATE CONSTRAINT c", []string{"NOT", "VALID"}, false},
+		{"ALTER TABLE t VALIDATE /* c */ CONSTRAINT c", []string{"VALIDATE", "CONSTRAINT"}, true},
+		{"-- CONCURRENTLY\nCREATE INDEX i ON t (a)", []string{"CONCURRENTLY"}, false},
+		{"COMMENT ON TABLE t IS 'built CONCURRENTLY'", []string{"CONCURRENTLY"}, false},
+		{`CREATE TABLE "concurrently" (a int)`, []string{"CONCURRENTLY"}, false},
+		{"SELECT $$ VACUUM $$", []string{"VACUUM"}, false},
+		{"CREATE INDEX concurrently_built ON t (a)", []string{"CONCURRENTLY"}, false},
+		{"CREATE INDEX i ON t (a)", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stmt, func(t *testing.T) {
+			if got := HasKeywords(tt.stmt, tt.keywords...); got != tt.want {
+				t.Errorf("HasKeywords(%q) = %v, want %v", tt.keywords, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsDataStatement(t *testing.T) {
+	tests := []struct {
+		stmt string
+		want bool
+	}{
+		{"INSERT INTO roles (name) VALUES ('admin')", true},
+		{"update users set active = true", true},
+		{"-- backfill\nDELETE FROM sessions", true},
+		{"WITH old AS (SELECT id FROM users) DELETE FROM users USING old", true},
+		{"SELECT setval('users_id_seq', 100)", true},
+		{"DO $$ BEGIN PERFORM 1; END $$", true},
+		{"TRUNCATE audit_log", true},
+		{"CREATE TABLE users (id int)", false},
+		{"ALTER TABLE users ADD COLUMN active bool DEFAULT false", false},
+		{"CREATE FUNCTION f() RETURNS void LANGUAGE sql AS $$ DELETE FROM t $$", false},
+		{"/* INSERT */ DROP TABLE old", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stmt, func(t *testing.T) {
+			if got := IsDataStatement(tt.stmt); got != tt.want {
+				t.Errorf("IsDataStatement() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package sqlparse
+
+import (
+	"strings"
+)
+
+// Statement is a single statement of a SQL script.
+type Statement struct {
+	SQL string
+	// Line is the 1-based line of the script the statement starts on.
+	Line int
+}
+
+// Split splits a SQL script into its statements, without their terminating semicolons. Semicolons in
+// comments, string literals, dollar-quoted bodies, quoted identifiers and BEGIN ATOMIC function bodies
+// do not end a statement.
+func Split(src string) []Statement {
+	var stmts []Statement
+	start := -1
+	// depth counts the BEGIN ATOMIC bodies and the CASE expressions within them that are still open.
+	depth := 0
+	prev := token{}
+	for pos := 0; ; {
+		tok := lex(src, pos)
+		pos = tok.end
+		if tok.kind == tokEOF || (tok.kind == tokPunct && tok.text == ";" && depth == 0) {
+			if start >= 0 {
+				stmts = append(stmts, Statement{
+					SQL:  strings.TrimSpace(src[start:tok.start]),
+					Line: 1 + strings.Count(src[:start], "\n"),
+				})
+			}
+			if tok.kind == tokEOF {
+				return stmts
+			}
+			start, depth, prev = -1, 0, token{}
+			continue
+		}
+		if start < 0 {
+			start = tok.start
+		}
+		switch {
+		case tok.is("ATOMIC") && prev.is("BEGIN"):
+			depth++
+		case tok.is("CASE") && depth > 0:
+			depth++
+		case tok.is("END") && depth > 0:
+			depth--
+		}
+		prev = tok
+	}
+}
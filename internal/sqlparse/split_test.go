@@ -0,0 +1,89 @@
+package sqlparse
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []Statement
+	}{
+		{
+			name: "statements and lines",
+			src:  "CREATE TABLE a (id int);\n\nCREATE TABLE b (id int);\nCREATE INDEX b_id ON b\n  (id);\n",
+			want: []Statement{
+				{SQL: "CREATE TABLE a (id int)", Line: 1},
+				{SQL: "CREATE TABLE b (id int)", Line: 3},
+				{SQL: "CREATE INDEX b_id ON b\n  (id)", Line: 4},
+			},
+		},
+		{
+			name: "last statement without semicolon",
+			src:  "SELECT 1; SELECT 2",
+			want: []Statement{{SQL: "SELECT 1", Line: 1}, {SQL: "SELECT 2", Line: 1}},
+		},
+		{
+			name: "empty statements and comments only",
+			src:  ";;\n-- nothing\n;",
+			want: nil,
+		},
+		{
+			name: "leading comments are left out",
+			src:  "-- users\n/* table */\nCREATE TABLE users (id int);",
+			want: []Statement{{SQL: "CREATE TABLE users (id int)", Line: 3}},
+		},
+		{
+			name: "semicolons in strings, identifiers and comments",
+			src:  "SELECT ';', E'\\';', \"a;b\" /* ; */ -- ;\nFROM t;\nSELECT 2;",
+			want: []Statement{
+				{SQL: "SELECT ';', E'\\';', \"a;b\" /* ; */ -- ;\nFROM t", Line: 1},
+				{SQL: "SELECT 2", Line: 3},
+			},
+		},
+		{
+			name: "dollar-quoted body",
+			src:  "CREATE FUNCTION f() RETURNS int AS $body$\nBEGIN\n  RETURN 1;\nEND;\n$body$ LANGUAGE plpgsql;\nSELECT f();",
+			want: []Statement{
+				{SQL: "CREATE FUNCTION f() RETURNS int AS $body$\nBEGIN\n  RETURN 1;\nEND;\n$body$ LANGUAGE plpgsql", Line: 1},
+				{SQL: "SELECT f()", Line: 6},
+			},
+		},
+		{
+			name: "BEGIN ATOMIC body",
+			src:  "CREATE FUNCTION f() RETURNS int LANGUAGE sql BEGIN ATOMIC SELECT 1; SELECT 2; END;\nSELECT f();",
+			want: []Statement{
+				{SQL: "CREATE FUNCTION f() RETURNS int LANGUAGE sql BEGIN ATOMIC SELECT 1; SELECT 2; END", Line: 1},
+				{SQL: "SELECT f()", Line: 2},
+			},
+		},
+		{
+			name: "CASE inside BEGIN ATOMIC",
+			src:  "CREATE PROCEDURE p(x int) BEGIN ATOMIC SELECT CASE WHEN x > 0 THEN 1 END; INSERT INTO t VALUES (x); END; SELECT 3;",
+			want: []Statement{
+				{SQL: "CREATE PROCEDURE p(x int) BEGIN ATOMIC SELECT CASE WHEN x > 0 THEN 1 END; INSERT INTO t VALUES (x); END", Line: 1},
+				{SQL: "SELECT 3", Line: 1},
+			},
+		},
+		{
+			name: "CASE outside BEGIN ATOMIC",
+			src:  "SELECT CASE WHEN true THEN 1 END; SELECT 2;",
+			want: []Statement{{SQL: "SELECT CASE WHEN true THEN 1 END", Line: 1}, {SQL: "SELECT 2", Line: 1}},
+		},
+		{
+			name: "transaction blocks are not atomic bodies",
+			src:  "BEGIN; SELECT 1; END;",
+			want: []Statement{{SQL: "BEGIN", Line: 1}, {SQL: "SELECT 1", Line: 1}, {SQL: "END", Line: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Split(tt.src)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Split(%q) = %+v, want %+v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Package tracing traces migration runs with OpenTelemetry. Spans are exported over OTLP/HTTP when an
+// endpoint is set with the standard OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT
+// environment variables, and are no-ops otherwise.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/cortea-ai/pg-migrant/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentation = "github.com/cortea-ai/pg-migrant"
+
+// maxSQLLen is the length SQL text is truncated to in span attributes.
+const maxSQLLen = 2048
+
+// Attributes of the spans.
+const (
+	ConfigKey    = attribute.Key("pgmigrant.config")
+	EnvKey       = attribute.Key("pgmigrant.env")
+	VersionKey   = attribute.Key("pgmigrant.version")
+	FileKey      = attribute.Key("pgmigrant.file")
+	StatementKey = attribute.Key("pgmigrant.statement")
+	HookKey      = attribute.Key("pgmigrant.hook")
+	SQLKey       = attribute.Key("db.query.text")
+)
+
+// Enabled reports whether an OTLP endpoint is configured.
+func Enabled() bool {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup installs a tracer provider exporting spans over OTLP/HTTP, if Enabled. The returned function
+// flushes the spans and must be called before exiting.
+func Setup(ctx context.Context, serviceVersion string) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", "pg-migrant"),
+		attribute.String("service.version", serviceVersion),
+	))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// ContextFromEnv returns ctx carrying the remote span context of the TRACEPARENT and TRACESTATE
+// environment variables, as set by CI systems, so that the spans of the run join their trace.
+func ContextFromEnv(ctx context.Context) context.Context {
+	carrier := propagation.MapCarrier{
+		"traceparent": os.Getenv("TRACEPARENT"),
+		"tracestate":  os.Getenv("TRACESTATE"),
+	}
+	return propagation.TraceContext{}.Extract(ctx, carrier)
+}
+
+// Start starts a span.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, recording err, with sensitive values redacted, if not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		msg := config.Redact(err.Error())
+		span.RecordError(errors.New(msg))
+		span.SetStatus(codes.Error, msg)
+	}
+	span.End()
+}
+
+// SQL returns the attribute holding sql, truncated.
+func SQL(sql string) attribute.KeyValue {
+	if len(sql) > maxSQLLen {
+		sql = strings.ToValidUTF8(sql[:maxSQLLen], "") + "..."
+	}
+	return SQLKey.String(sql)
+}
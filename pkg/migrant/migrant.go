@@ -0,0 +1,63 @@
+// Package migrant is the Go API of pg-migrant.
+//
+// Migrations written in Go are registered by version from init functions, and run alongside the SQL
+// migrations of the migration directory by a binary built on the pg-migrant commands:
+//
+//	func init() {
+//		migrant.Register("0042", backfillDisplayNames)
+//	}
+//
+//	func main() {
+//		pgmigrant.Main(version)
+//	}
+//
+// Services can also apply their migrations at startup with Migrate, reading the config file,
+// migrations and schema files from any fs.FS, such as an embed.FS. For more control, a Migrator lists
+// the pending migrations, applies them, reports the status of the database and plans schema changes,
+// returning typed results rather than printing:
+//
+//	m, err := migrant.New(migrant.Config{DBURL: url, MigrationDir: "migrations", Logger: logger})
+//	...
+//	result, err := m.Apply(ctx, migrant.ApplyOptions{})
+package migrant
+
+import (
+	"context"
+	"io/fs"
+
+	"github.com/cortea-ai/pg-migrant/internal/migrate"
+)
+
+// MigrationFunc is a migration written in Go. It runs in the transaction that records it as applied, so
+// that a failing migration leaves no trace.
+type MigrationFunc = migrate.Func
+
+// GoMigration is a registered Go migration.
+type GoMigration = migrate.GoMigration
+
+// Register registers a Go migration under version, which shares the numbering of the SQL migrations. It
+// panics if fn is nil or version is already registered.
+func Register(version string, fn MigrationFunc) {
+	migrate.Register(version, fn)
+}
+
+// Registered returns the registered Go migrations, ordered by version.
+func Registered() []GoMigration {
+	return migrate.Registered()
+}
+
+// Migrate applies the pending migrations of env without prompting. The config file at configPath, and
+// the migrations and schema files it references, are read from fsys; their paths must be relative to
+// its root. vars sets input variables of the config, as --var does.
+func Migrate(ctx context.Context, fsys fs.FS, configPath, env string, vars map[string]string) error {
+	c, err := LoadConfig(fsys, configPath, env, vars)
+	if err != nil {
+		return err
+	}
+	m, err := New(c)
+	if err != nil {
+		return err
+	}
+	_, err = m.Apply(ctx, ApplyOptions{})
+	return err
+}
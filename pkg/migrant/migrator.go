@@ -0,0 +1,305 @@
+package migrant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"time"
+
+	"github.com/cortea-ai/pg-migrant/internal/config"
+	"github.com/cortea-ai/pg-migrant/internal/engine"
+)
+
+// ErrAborted is returned by Apply when Config.Approve rejects a migration.
+var ErrAborted = engine.ErrAborted
+
+// ErrNoSchemaFiles is returned by Plan when the config has no schema files.
+var ErrNoSchemaFiles = engine.ErrNoSchemaFiles
+
+// MigrationError is returned by Apply when a migration fails.
+type MigrationError struct {
+	Migration Migration
+	Err       error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("migration %s failed: %v", e.Migration.Filename, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// Config configures a Migrator. Paths are relative to the root of FS.
+type Config struct {
+	DBURL        string
+	MigrationDir string
+	// RepeatableDir holds the repeatable migrations, re-run whenever they change. It is optional.
+	RepeatableDir  string
+	SchemaFiles    []string
+	IncludeSchemas []string
+	ExcludeSchemas []string
+	// FS is the file system the migrations and schema files are read from, the operating system's if nil.
+	FS fs.FS
+	// Logger receives the progress of the runs. Nothing is logged if nil.
+	Logger *slog.Logger
+	// Approve is called before each migration is applied. Apply stops with ErrAborted when it returns
+	// false. Every migration is approved if nil.
+	Approve func(ctx context.Context, m Migration) (bool, error)
+
+	// conf is the config file the fields were loaded from, if any, which holds the settings not exposed
+	// above, such as the diff settings.
+	conf *config.Config
+}
+
+// LoadConfig reads the config file at path from fsys, as the commands do, and returns the settings of
+// env, or of the default env if env is empty. vars sets input variables of the config, as --var does.
+func LoadConfig(fsys fs.FS, path, env string, vars map[string]string) (Config, error) {
+	conf, err := config.GetConfigFS(fsys, path, env, vars)
+	if err != nil {
+		return Config{}, err
+	}
+	e := conf.SelectedEnv
+	return Config{
+		DBURL:          e.DBUrl,
+		MigrationDir:   e.MigrationDir,
+		RepeatableDir:  e.RepeatableDir,
+		SchemaFiles:    e.SchemaFiles,
+		IncludeSchemas: e.IncludeSchemas,
+		ExcludeSchemas: e.ExcludeSchemas,
+		FS:             fsys,
+		conf:           conf,
+	}, nil
+}
+
+// Migrator runs the migrations of a database. It neither prints nor reads from stdin.
+type Migrator struct {
+	conf    *config.Config
+	logger  *slog.Logger
+	approve func(ctx context.Context, m Migration) (bool, error)
+}
+
+// New returns a Migrator for c.
+func New(c Config) (*Migrator, error) {
+	if c.DBURL == "" {
+		return nil, errors.New("migrant: DBURL is required")
+	}
+	conf := &config.Config{}
+	if c.conf != nil {
+		copied := *c.conf
+		conf = &copied
+	}
+	conf.FS = c.FS
+	conf.SelectedEnv.DBUrl = c.DBURL
+	conf.SelectedEnv.MigrationDir = c.MigrationDir
+	conf.SelectedEnv.RepeatableDir = c.RepeatableDir
+	conf.SelectedEnv.SchemaFiles = c.SchemaFiles
+	conf.SelectedEnv.IncludeSchemas = c.IncludeSchemas
+	conf.SelectedEnv.ExcludeSchemas = c.ExcludeSchemas
+	return &Migrator{conf: conf, logger: c.Logger, approve: c.Approve}, nil
+}
+
+// Migration is a migration of the migration directory, a registered Go migration, or a repeatable
+// migration.
+type Migration struct {
+	// Version is empty for repeatable migrations.
+	Version  string
+	Filename string
+	// Content is the SQL of the migration, or a description of a Go migration.
+	Content    string
+	Go         bool
+	Repeatable bool
+}
+
+func newMigration(m engine.Migration) Migration {
+	return Migration{
+		Version:    m.Version,
+		Filename:   m.Filename,
+		Content:    m.Content,
+		Go:         m.Func != nil,
+		Repeatable: m.Repeatable,
+	}
+}
+
+func newMigrations(ms []engine.Migration) []Migration {
+	migrations := make([]Migration, 0, len(ms))
+	for _, m := range ms {
+		migrations = append(migrations, newMigration(m))
+	}
+	return migrations
+}
+
+// Pending returns the migrations pending on the database, then its changed repeatable migrations, in
+// the order Apply runs them. The database is only read.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	pending, err := engine.FindPending(ctx, m.conf, m.logger)
+	if err != nil {
+		return nil, err
+	}
+	return append(newMigrations(pending.Migrations), newMigrations(pending.Repeatables)...), nil
+}
+
+// ApplyOptions configures Apply.
+type ApplyOptions struct {
+	// DryRun executes the migrations in a transaction that is rolled back instead of applying them.
+	// Statements of non-transactional migrations are skipped.
+	DryRun bool
+}
+
+// StatementResult is the outcome of a statement executed by a dry run.
+type StatementResult struct {
+	// Line is the line of the statement in its migration, 0 for Go migrations.
+	Line         int
+	SQL          string
+	Duration     time.Duration
+	RowsAffected int64
+	Skipped      bool
+	Err          error
+}
+
+// MigrationResult is a migration run by Apply.
+type MigrationResult struct {
+	Migration
+	Duration time.Duration
+	// Statements holds the outcome of each statement of a dry run.
+	Statements []StatementResult
+	// Skipped is set for non-transactional migrations skipped by a dry run.
+	Skipped bool
+}
+
+// ApplyResult is the outcome of Apply.
+type ApplyResult struct {
+	// Migrations lists the migrations run, in order, including the failing one of a dry run.
+	Migrations []MigrationResult
+	DryRun     bool
+}
+
+// Apply applies the pending migrations, then the changed repeatable migrations, while holding the lock
+// that serializes runs against the database. On failure, it returns the migrations run before along with
+// a *MigrationError.
+func (m *Migrator) Apply(ctx context.Context, opts ApplyOptions) (ApplyResult, error) {
+	if opts.DryRun {
+		return m.dryRun(ctx)
+	}
+	var approve func(ctx context.Context, cm engine.Migration) (bool, error)
+	if m.approve != nil {
+		approve = func(ctx context.Context, cm engine.Migration) (bool, error) {
+			return m.approve(ctx, newMigration(cm))
+		}
+	}
+	applied, err := engine.ApplyMigrations(ctx, m.conf, engine.ApplyOptions{Approve: approve, Logger: m.logger})
+	result := ApplyResult{}
+	for _, a := range applied {
+		result.Migrations = append(result.Migrations, MigrationResult{Migration: newMigration(a.Migration), Duration: a.Duration})
+	}
+	return result, convertError(err)
+}
+
+func (m *Migrator) dryRun(ctx context.Context) (ApplyResult, error) {
+	results, err := engine.DryRunMigrations(ctx, m.conf, m.logger)
+	result := ApplyResult{DryRun: true}
+	for _, r := range results {
+		mr := MigrationResult{Migration: newMigration(r.Migration), Skipped: r.Skipped}
+		for _, s := range r.Statements {
+			mr.Duration += s.Duration
+			mr.Statements = append(mr.Statements, StatementResult{
+				Line:         s.Line,
+				SQL:          s.SQL,
+				Duration:     s.Duration,
+				RowsAffected: s.RowsAffected,
+				Skipped:      s.Skipped,
+				Err:          s.Err,
+			})
+		}
+		result.Migrations = append(result.Migrations, mr)
+	}
+	return result, convertError(err)
+}
+
+func convertError(err error) error {
+	var migrationErr *engine.MigrationError
+	if errors.As(err, &migrationErr) {
+		return &MigrationError{Migration: newMigration(migrationErr.Migration), Err: migrationErr.Err}
+	}
+	return err
+}
+
+// HistoryEntry is a migration recorded in the database.
+type HistoryEntry struct {
+	Version  string
+	Checksum string
+	// Status is "applied", "failed", "marked" or "baseline".
+	Status string
+	Note   string
+}
+
+// Status is the state of the migrations of the database.
+type Status struct {
+	// CurrentVersion is empty if no migration was applied.
+	CurrentVersion string
+	// History lists the migrations recorded in the database, ordered by version.
+	History []HistoryEntry
+	// Pending lists the pending migrations, then the changed repeatable migrations.
+	Pending []Migration
+	// Modified lists the versions of the applied migrations whose content changed since.
+	Modified []string
+}
+
+// Status reports the current version of the database, its migration history and the pending
+// migrations. The database is only read.
+func (m *Migrator) Status(ctx context.Context) (Status, error) {
+	report, err := engine.Status(ctx, m.conf, m.logger)
+	if err != nil {
+		return Status{}, err
+	}
+	status := Status{
+		CurrentVersion: report.CurrentVersion,
+		Pending:        append(newMigrations(report.Migrations), newMigrations(report.Repeatables)...),
+		Modified:       report.Modified,
+	}
+	for _, e := range report.History {
+		status.History = append(status.History, HistoryEntry(e))
+	}
+	return status, nil
+}
+
+// Hazard is a risk of a planned statement, such as a table rewrite.
+type Hazard struct {
+	Type    string
+	Message string
+}
+
+// PlanStatement is a statement of a plan, with the timeouts it should run with.
+type PlanStatement struct {
+	DDL         string
+	Timeout     time.Duration
+	LockTimeout time.Duration
+	Hazards     []Hazard
+}
+
+// Plan turns the schema of the database into the one of the schema files.
+type Plan struct {
+	Statements []PlanStatement
+	// Migrations holds the content of the migration files the plan is written as by diff, in order.
+	Migrations []string
+}
+
+// Plan diffs the database against the schema files, which are loaded into a temp database on the same
+// instance. The database is only read. The plan is empty if the schemas match.
+func (m *Migrator) Plan(ctx context.Context) (Plan, error) {
+	generated, err := engine.GeneratePlan(ctx, m.conf, m.logger)
+	if err != nil {
+		return Plan{}, err
+	}
+	plan := Plan{Migrations: generated.Migrations}
+	for _, stmt := range generated.Statements {
+		s := PlanStatement{DDL: stmt.DDL, Timeout: stmt.Timeout, LockTimeout: stmt.LockTimeout}
+		for _, h := range stmt.Hazards {
+			s.Hazards = append(s.Hazards, Hazard{Type: h.Type, Message: h.Message})
+		}
+		plan.Statements = append(plan.Statements, s)
+	}
+	return plan, nil
+}
@@ -0,0 +1,60 @@
+package migrant
+
+import (
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadConfig(t *testing.T) {
+	fsys := fstest.MapFS{
+		"pgmigrant.hcl": {Data: []byte(`
+variable "db_host" {
+  default = "localhost"
+}
+
+env "dev" {
+  db_url          = "postgres://${var.db_host}:5432/dev"
+  migration_dir   = "db/migrations"
+  repeatable_dir  = "db/repeatable"
+  schema_files    = ["db/schema/"]
+  include_schemas = ["public", "billing"]
+}
+`)},
+		"db/migrations/0001_users.sql": {Data: []byte("CREATE TABLE users (id int);")},
+	}
+	c, err := LoadConfig(fsys, "pgmigrant.hcl", "", map[string]string{"db_host": "db.internal"})
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if c.DBURL != "postgres://db.internal:5432/dev" {
+		t.Errorf("DBURL = %q, want the db_host variable to be set", c.DBURL)
+	}
+	if c.MigrationDir != "db/migrations" || c.RepeatableDir != "db/repeatable" {
+		t.Errorf("MigrationDir, RepeatableDir = %q, %q", c.MigrationDir, c.RepeatableDir)
+	}
+	if !slices.Equal(c.SchemaFiles, []string{"db/schema/"}) || !slices.Equal(c.IncludeSchemas, []string{"public", "billing"}) {
+		t.Errorf("SchemaFiles, IncludeSchemas = %q, %q", c.SchemaFiles, c.IncludeSchemas)
+	}
+	if c.FS == nil {
+		t.Errorf("FS = nil, want the file system the config was read from")
+	}
+
+	m, err := New(c)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if m.conf.GetFS() == nil || m.conf.GetMigrationDir() != "db/migrations" {
+		t.Errorf("New() config = %+v, want the loaded settings", m.conf.SelectedEnv)
+	}
+
+	if _, err := LoadConfig(fsys, "pgmigrant.hcl", "prod", nil); err == nil {
+		t.Errorf("LoadConfig() of an unknown env succeeded")
+	}
+}
+
+func TestNewRequiresDBURL(t *testing.T) {
+	if _, err := New(Config{MigrationDir: "migrations"}); err == nil {
+		t.Errorf("New() without DBURL succeeded")
+	}
+}